@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 
 	"github.com/MiguelVivar/insta-mock/internal/generator"
@@ -13,11 +15,29 @@ import (
 )
 
 var (
-	port    string
-	count   int
-	watch   bool
-	chaos   bool
-	version = "0.2.0"
+	port       string
+	count      int
+	watch      bool
+	chaos      bool
+	stubsFile  string
+	specFile   string
+	scriptsDir string
+	proxyURL   string
+	proxyHdrs  []string
+	proxyRes   []string
+	format     string
+	envFile    string
+	idFlags    []string
+	idFields   []string
+	version    = "0.2.0"
+
+	recordTarget  string
+	outFile       string
+	stubsOutFile  string
+	redactHeaders []string
+	redactFields  []string
+	serverURL     string
+	openapiOut    string
 )
 
 func main() {
@@ -28,9 +48,9 @@ func main() {
 	}
 
 	serveCmd := &cobra.Command{
-		Use:   "serve <json-file>",
-		Short: "Start the mock API server from a JSON file",
-		Args:  cobra.ExactArgs(1),
+		Use:   "serve [json-file]",
+		Short: "Start the mock API server from a JSON file and/or an OpenAPI spec",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  runServe,
 	}
 
@@ -38,32 +58,138 @@ func main() {
 	serveCmd.Flags().IntVarP(&count, "count", "c", 0, "Generate N additional fake items per resource")
 	serveCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch JSON file for changes (hot-reload)")
 	serveCmd.Flags().BoolVar(&chaos, "chaos", false, "Enable chaos mode (random failures/latency)")
+	serveCmd.Flags().StringVar(&specFile, "openapi", "", "Mock every operation of an OpenAPI 3 spec (YAML or JSON)")
+	serveCmd.Flags().StringVar(&stubsFile, "stubs", "", "Load request-matching stubs from a JSON file")
+	serveCmd.Flags().StringVar(&scriptsDir, "scripts", "", "Load <resource>.star hook scripts from a directory")
+	serveCmd.Flags().StringVar(&proxyURL, "proxy", "", "Forward unknown routes to an upstream URL")
+	serveCmd.Flags().StringArrayVar(&proxyHdrs, "proxy-header", nil, "Set (\"Name: value\") or remove (\"Name:\") a header on proxied requests")
+	serveCmd.Flags().StringSliceVar(&proxyRes, "proxy-resource", nil, "Forward these resources upstream instead of mocking them")
+	serveCmd.Flags().StringVar(&format, "format", server.FormatJSON, "Default response format: json, jsonapi or hal")
+	serveCmd.Flags().StringVar(&envFile, "envelope", "", "Wrap lists, items and errors using an envelope JSON file")
+	serveCmd.Flags().StringArrayVar(&idFlags, "id", nil, "Id strategy for new items, [resource=]uuid|uuidv7|ulid|nanoid|autoincrement|slug:<field>")
+	serveCmd.Flags().StringArrayVar(&idFields, "id-field", nil, "Id field name, [resource=]<field> (default \"id\")")
+
+	recordCmd := &cobra.Command{
+		Use:   "record",
+		Short: "Proxy an upstream API and capture its responses into a data file",
+		Args:  cobra.NoArgs,
+		RunE:  runRecord,
+	}
+
+	recordCmd.Flags().StringVarP(&port, "port", "p", "3000", "Port to run the recording proxy on")
+	recordCmd.Flags().StringVarP(&recordTarget, "target", "t", "", "Upstream API base URL")
+	recordCmd.Flags().StringVarP(&outFile, "out", "o", "db.json", "Data file to write captured resources to")
+	recordCmd.Flags().StringVar(&stubsOutFile, "stubs-out", "stubs.json", "Stubs file to write non-CRUD exchanges to")
+	recordCmd.Flags().StringSliceVar(&redactHeaders, "redact-header", []string{"Authorization", "Cookie", "Set-Cookie"}, "Response headers to redact")
+	recordCmd.Flags().StringSliceVar(&redactFields, "redact-field", []string{"password", "token"}, "JSON fields to redact")
+	recordCmd.MarkFlagRequired("target")
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import captured traffic into a data file and stubs",
+	}
+
+	importHARCmd := &cobra.Command{
+		Use:   "har <har-file>",
+		Short: "Turn the JSON API calls of a HAR file into resources and stubs",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runImportHAR,
+	}
+
+	importHARCmd.Flags().StringVarP(&outFile, "out", "o", "db.json", "Data file to write resources to")
+	importHARCmd.Flags().StringVar(&stubsOutFile, "stubs-out", "stubs.json", "Stubs file to write non-CRUD calls to")
+	importHARCmd.Flags().StringSliceVar(&redactHeaders, "redact-header", []string{"Authorization", "Cookie", "Set-Cookie"}, "Response headers to redact")
+	importHARCmd.Flags().StringSliceVar(&redactFields, "redact-field", []string{"password", "token"}, "JSON fields to redact")
+	importCmd.AddCommand(importHARCmd)
+
+	openapiCmd := &cobra.Command{
+		Use:   "openapi <json-file>",
+		Short: "Generate an OpenAPI 3.1 document for the resources in a JSON file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runOpenAPI,
+	}
+
+	openapiCmd.Flags().StringVarP(&openapiOut, "out", "o", "", "Write the document to a file instead of stdout")
+	openapiCmd.Flags().StringVar(&serverURL, "server", "http://localhost:3000", "Server URL listed in the document")
+	openapiCmd.Flags().StringArrayVar(&idFields, "id-field", nil, "Id field name, [resource=]<field> (default \"id\")")
 
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(recordCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(openapiCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
-func runServe(cmd *cobra.Command, args []string) error {
-	filePath := args[0]
-
+// loadDataFile reads and parses a JSON data file.
+func loadDataFile(filePath string) (map[string]interface{}, error) {
 	// Read JSON file
 	fileData, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("❌ Error reading file '%s': %w", filePath, err)
+		return nil, fmt.Errorf("❌ Error reading file '%s': %w", filePath, err)
 	}
 
 	// Parse JSON
 	var data map[string]interface{}
 	if err := json.Unmarshal(fileData, &data); err != nil {
-		return fmt.Errorf("❌ Invalid JSON in '%s': %w", filePath, err)
+		return nil, fmt.Errorf("❌ Invalid JSON in '%s': %w", filePath, err)
+	}
+	return data, nil
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && specFile == "" {
+		return fmt.Errorf("❌ Provide a JSON data file, an --openapi spec, or both")
+	}
+	if !slices.Contains(server.Formats, format) {
+		return fmt.Errorf("❌ Unknown --format '%s' (use %s)", format, strings.Join(server.Formats, ", "))
+	}
+	ids, err := server.ParseIDFlags(idFlags, idFields)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	filePath := ""
+	data := make(map[string]interface{})
+	if len(args) > 0 {
+		filePath = args[0]
+		var err error
+		data, err = loadDataFile(filePath)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Generate additional fake data
+	// Load OpenAPI spec
+	var spec *server.Spec
+	if specFile != "" {
+		var err error
+		spec, err = server.LoadSpec(specFile)
+		if err != nil {
+			return fmt.Errorf("❌ Error loading spec '%s': %w", specFile, err)
+		}
+	}
+
+	// Generate additional fake data; the engine gives generated items ids from their id strategy
 	if count > 0 {
+		sizes := make(map[string]int)
+		for key, value := range data {
+			if arr, ok := value.([]interface{}); ok {
+				sizes[key] = len(arr)
+			}
+		}
 		data = generator.ExpandData(data, count)
+		for key, value := range data {
+			if arr, ok := value.([]interface{}); ok {
+				for _, item := range arr[sizes[key]:] {
+					if m, ok := item.(map[string]interface{}); ok {
+						delete(m, ids.Resolve(key).Field)
+					}
+				}
+			}
+		}
 	}
 
 	// Count resources and items
@@ -79,11 +205,69 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Load stubs
+	var stubs []server.Stub
+	if stubsFile != "" {
+		var err error
+		stubs, err = server.LoadStubs(stubsFile)
+		if err != nil {
+			return fmt.Errorf("❌ Error loading stubs '%s': %w", stubsFile, err)
+		}
+	}
+
+	// Load hook scripts
+	var scripts map[string]*server.Script
+	if scriptsDir != "" {
+		var err error
+		scripts, err = server.LoadScripts(scriptsDir)
+		if err != nil {
+			return fmt.Errorf("❌ Error loading scripts: %w", err)
+		}
+	}
+
+	// Load response envelope
+	var envelope *server.Envelope
+	if envFile != "" {
+		var err error
+		envelope, err = server.LoadEnvelope(envFile)
+		if err != nil {
+			return fmt.Errorf("❌ Error loading envelope '%s': %w", envFile, err)
+		}
+	}
+
+	// Proxy fallthrough
+	var proxyConfig *server.ProxyConfig
+	if proxyURL != "" {
+		proxyConfig = &server.ProxyConfig{
+			Target:    proxyURL,
+			Headers:   make(map[string]string),
+			Resources: proxyRes,
+		}
+		for _, h := range proxyHdrs {
+			name, value, err := server.ParseHeaderFlag(h)
+			if err != nil {
+				return fmt.Errorf("❌ %w", err)
+			}
+			if value == "" {
+				proxyConfig.RemoveHeaders = append(proxyConfig.RemoveHeaders, name)
+				continue
+			}
+			proxyConfig.Headers[name] = value
+		}
+	}
+
 	// Create engine with config
 	config := server.EngineConfig{
 		EnableLogger: true,
 		ChaosMode:    chaos,
 		ChaosPercent: 15,
+		Stubs:        stubs,
+		Scripts:      scripts,
+		Proxy:        proxyConfig,
+		Spec:         spec,
+		Format:       format,
+		Envelope:     envelope,
+		IDs:          ids,
 	}
 	engine := server.NewEngineWithConfig(data, config)
 
@@ -91,7 +275,12 @@ func runServe(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 	fmt.Println("  🚀 \033[1;36mInsta-Mock\033[0m \033[90mv" + version + "\033[0m")
 	fmt.Println("  \033[90m─────────────────────────────────────\033[0m")
-	fmt.Printf("  📁 File:      \033[33m%s\033[0m\n", filePath)
+	if filePath != "" {
+		fmt.Printf("  📁 File:      \033[33m%s\033[0m\n", filePath)
+	}
+	if spec != nil {
+		fmt.Printf("  📘 Spec:      \033[33m%s\033[0m \033[90m(%d operations)\033[0m\n", specFile, len(spec.Operations))
+	}
 	fmt.Printf("  📦 Resources: \033[32m%d\033[0m\n", resourceCount)
 	fmt.Printf("  📊 Items:     \033[32m%d\033[0m", totalItems)
 	if count > 0 {
@@ -99,6 +288,9 @@ func runServe(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println()
 	fmt.Printf("  🌐 Server:    \033[1;32mhttp://localhost:%s\033[0m\n", port)
+	fmt.Printf("  🧭 Explorer:  \033[36mhttp://localhost:%s%s\033[0m\n", port, server.ExplorerPath)
+	fmt.Printf("  🕸️  GraphQL:   \033[36mhttp://localhost:%s/graphql\033[0m\n", port)
+	fmt.Printf("  📡 Live:      \033[36mws://localhost:%s%s\033[0m\n", port, server.EventsSocketPath)
 
 	// Feature flags
 	features := []string{}
@@ -108,6 +300,24 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if chaos {
 		features = append(features, "💥 chaos")
 	}
+	if len(stubs) > 0 {
+		features = append(features, fmt.Sprintf("🎯 %d stubs", len(stubs)))
+	}
+	if len(scripts) > 0 {
+		features = append(features, fmt.Sprintf("📜 %d scripts", len(scripts)))
+	}
+	if proxyURL != "" {
+		features = append(features, "🔀 proxy → "+proxyURL)
+	}
+	if format != server.FormatJSON {
+		features = append(features, "🧾 "+format+" responses")
+	}
+	if envelope != nil {
+		features = append(features, "✉️  envelope")
+	}
+	if len(idFlags) > 0 || len(idFields) > 0 {
+		features = append(features, "🆔 ids: "+strings.Join(slices.Concat(idFlags, idFields), ", "))
+	}
 	if len(features) > 0 {
 		fmt.Printf("  ⚡ Features:  %s\n", features[0])
 		for i := 1; i < len(features); i++ {
@@ -125,6 +335,11 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 		fmt.Printf("    \033[36m%-12s\033[0m \033[90m%d items\033[0m\n", "/"+key, itemCount)
 	}
+	if spec != nil {
+		for _, op := range spec.Operations {
+			fmt.Printf("    \033[36m%-12s\033[0m \033[90m%s\033[0m\n", op.Path, op.Method)
+		}
+	}
 
 	fmt.Println()
 	fmt.Println("  \033[1mQuery Parameters:\033[0m")
@@ -137,7 +352,7 @@ func runServe(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 
 	// Setup hot-reload watcher
-	if watch {
+	if watch && filePath != "" {
 		watcher, err := server.NewWatcher(filePath, engine)
 		if err != nil {
 			fmt.Printf("  ⚠️  \033[33mHot-reload unavailable: %v\033[0m\n", err)
@@ -165,3 +380,117 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Start server
 	return engine.Start(":" + port)
 }
+
+func runRecord(cmd *cobra.Command, args []string) error {
+	recorder := server.NewRecorder(server.RecordConfig{
+		Target: recordTarget,
+		Redact: server.RedactConfig{
+			Headers: redactHeaders,
+			Fields:  redactFields,
+		},
+	})
+	recorder.OnRecord = func(ex server.Exchange) {
+		fmt.Printf("  \033[90m●\033[0m %-6s %s \033[90m→ %d\033[0m\n", ex.Method, ex.Path, ex.Status)
+	}
+
+	// Print banner
+	fmt.Println()
+	fmt.Println("  🎙️  \033[1;36mInsta-Mock Recorder\033[0m \033[90mv" + version + "\033[0m")
+	fmt.Println("  \033[90m─────────────────────────────────────\033[0m")
+	fmt.Printf("  🎯 Target:    \033[33m%s\033[0m\n", recordTarget)
+	fmt.Printf("  🌐 Proxy:     \033[1;32mhttp://localhost:%s\033[0m\n", port)
+	fmt.Printf("  💾 Output:    \033[33m%s\033[0m, \033[33m%s\033[0m\n", outFile, stubsOutFile)
+	fmt.Println("  \033[90m─────────────────────────────────────\033[0m")
+	fmt.Println()
+	fmt.Println("  \033[90mPress Ctrl+C to stop and save\033[0m")
+	fmt.Println()
+
+	// Graceful shutdown
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		recorder.Shutdown()
+	}()
+
+	if err := recorder.Start(":" + port); err != nil {
+		return err
+	}
+
+	if err := recorder.Save(outFile, stubsOutFile); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	resources, items, stubs := recorder.Summary()
+	fmt.Printf("\n  ✅ Captured %d requests: \033[32m%d\033[0m resources, \033[32m%d\033[0m items, \033[32m%d\033[0m stubs\n", recorder.Count(), resources, items, stubs)
+	if stubs > 0 {
+		fmt.Printf("  \033[90mRun: imock serve %s --stubs %s\033[0m\n\n", outFile, stubsOutFile)
+	} else {
+		fmt.Printf("  \033[90mRun: imock serve %s\033[0m\n\n", outFile)
+	}
+	return nil
+}
+
+func runImportHAR(cmd *cobra.Command, args []string) error {
+	harPath := args[0]
+
+	exchanges, err := server.LoadHAR(harPath)
+	if err != nil {
+		return fmt.Errorf("❌ Error importing '%s': %w", harPath, err)
+	}
+
+	fixtures := server.NewFixtures(server.RedactConfig{
+		Headers: redactHeaders,
+		Fields:  redactFields,
+	})
+	for _, ex := range exchanges {
+		fixtures.Add(ex)
+	}
+
+	if err := fixtures.Save(outFile, stubsOutFile); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	resources, items, stubs := fixtures.Summary()
+	fmt.Printf("\n  ✅ Imported %d JSON calls from \033[33m%s\033[0m: \033[32m%d\033[0m resources, \033[32m%d\033[0m items, \033[32m%d\033[0m stubs\n", len(exchanges), harPath, resources, items, stubs)
+	if stubs > 0 {
+		fmt.Printf("  \033[90mRun: imock serve %s --stubs %s\033[0m\n\n", outFile, stubsOutFile)
+	} else {
+		fmt.Printf("  \033[90mRun: imock serve %s\033[0m\n\n", outFile)
+	}
+	return nil
+}
+
+func runOpenAPI(cmd *cobra.Command, args []string) error {
+	filePath := args[0]
+
+	data, err := loadDataFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	ids, err := server.ParseIDFlags(nil, idFields)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	engine := server.NewEngineWithConfig(data, server.EngineConfig{IDs: ids})
+	doc, err := json.MarshalIndent(engine.OpenAPI(server.OpenAPIInfo{
+		Title:     "Insta-Mock API",
+		Version:   version,
+		ServerURL: serverURL,
+	}), "", "  ")
+	if err != nil {
+		return fmt.Errorf("❌ Error encoding OpenAPI document: %w", err)
+	}
+
+	if openapiOut == "" {
+		fmt.Println(string(doc))
+		return nil
+	}
+	if err := os.WriteFile(openapiOut, append(doc, '\n'), 0o644); err != nil {
+		return fmt.Errorf("❌ Error writing '%s': %w", openapiOut, err)
+	}
+	fmt.Printf("  ✅ OpenAPI document written to \033[33m%s\033[0m\n", openapiOut)
+	return nil
+}
@@ -0,0 +1,288 @@
+// Package generator provides OpenAPI schema-based data generation.
+package generator
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+)
+
+// maxSchemaDepth stops generation of deeply nested or recursive schemas.
+const maxSchemaDepth = 8
+
+// GenerateFromSchema returns a fake value matching an OpenAPI schema.
+// References ($ref) are resolved against root, the whole OpenAPI document.
+func GenerateFromSchema(schema map[string]interface{}, root map[string]interface{}) interface{} {
+	return generateSchemaValue("", schema, root, 0)
+}
+
+// ResolveSchemaRef follows $ref pointers (e.g. "#/components/schemas/User") until a concrete schema is found.
+func ResolveSchemaRef(schema map[string]interface{}, root map[string]interface{}) map[string]interface{} {
+	for i := 0; i < maxSchemaDepth; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		target, ok := lookupPointer(root, ref)
+		if !ok {
+			return map[string]interface{}{}
+		}
+		schema = target
+	}
+	return schema
+}
+
+// lookupPointer resolves a local JSON pointer such as "#/components/schemas/User".
+func lookupPointer(root map[string]interface{}, ref string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, false
+	}
+
+	var current interface{} = root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = m[part]
+	}
+
+	result, ok := current.(map[string]interface{})
+	return result, ok
+}
+
+// generateSchemaValue generates a value for a schema; name is the property name, used for inference.
+func generateSchemaValue(name string, schema map[string]interface{}, root map[string]interface{}, depth int) interface{} {
+	if depth > maxSchemaDepth || schema == nil {
+		return nil
+	}
+	schema = ResolveSchemaRef(schema, root)
+
+	if example, ok := schema["example"]; ok {
+		return example
+	}
+	if examples, ok := schema["examples"].([]interface{}); ok && len(examples) > 0 {
+		return examples[0]
+	}
+	if value, ok := schema["const"]; ok {
+		return value
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[gofakeit.Number(0, len(enum)-1)]
+	}
+	if value, ok := schema["default"]; ok && depth > 0 {
+		return value
+	}
+
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options, ok := schema[key].([]interface{}); ok && len(options) > 0 {
+			option, _ := options[gofakeit.Number(0, len(options)-1)].(map[string]interface{})
+			return generateSchemaValue(name, option, root, depth+1)
+		}
+	}
+	if parts, ok := schema["allOf"].([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, part := range parts {
+			p, _ := part.(map[string]interface{})
+			if obj, ok := generateSchemaValue(name, p, root, depth+1).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		return generateObject(schema, root, depth)
+	case "array":
+		return generateArray(name, schema, root, depth)
+	case "string":
+		return generateString(name, schema)
+	case "integer":
+		return generateNumber(schema, true)
+	case "number":
+		return generateNumber(schema, false)
+	case "boolean":
+		return gofakeit.Bool()
+	case "null":
+		return nil
+	}
+	return nil
+}
+
+// schemaType returns the type of a schema, picking the first non-null type of
+// OpenAPI 3.1 type arrays and inferring "object"/"array" from their keywords.
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+		return "null"
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return ""
+}
+
+// generateObject generates every declared property of an object schema.
+func generateObject(schema map[string]interface{}, root map[string]interface{}, depth int) map[string]interface{} {
+	result := make(map[string]interface{})
+	properties, _ := schema["properties"].(map[string]interface{})
+	for prop, raw := range properties {
+		propSchema, _ := raw.(map[string]interface{})
+		result[prop] = generateSchemaValue(prop, propSchema, root, depth+1)
+	}
+	return result
+}
+
+// generateArray generates between minItems and maxItems elements (1 to 3 by default).
+func generateArray(name string, schema map[string]interface{}, root map[string]interface{}, depth int) []interface{} {
+	minItems := intKeyword(schema, "minItems", 1)
+	maxItems := intKeyword(schema, "maxItems", minItems+2)
+	if maxItems < minItems {
+		maxItems = minItems
+	}
+
+	items, _ := schema["items"].(map[string]interface{})
+	n := gofakeit.Number(minItems, maxItems)
+	result := make([]interface{}, n)
+	for i := range result {
+		result[i] = generateSchemaValue(name, items, root, depth+1)
+	}
+	return result
+}
+
+// generateString generates a string honouring format, pattern-free length limits and the property name.
+func generateString(name string, schema map[string]interface{}) string {
+	var value string
+	switch format, _ := schema["format"].(string); format {
+	case "uuid":
+		value = uuid.New().String()
+	case "email":
+		value = gofakeit.Email()
+	case "date":
+		value = gofakeit.Date().Format("2006-01-02")
+	case "date-time":
+		value = gofakeit.Date().Format("2006-01-02T15:04:05Z")
+	case "time":
+		value = gofakeit.Date().Format("15:04:05")
+	case "uri", "url", "uri-reference":
+		value = gofakeit.URL()
+	case "hostname":
+		value = gofakeit.DomainName()
+	case "ipv4":
+		value = gofakeit.IPv4Address()
+	case "ipv6":
+		value = gofakeit.IPv6Address()
+	case "password":
+		value = gofakeit.Password(true, true, true, true, false, 12)
+	default:
+		if ft := InferFieldType(name); ft != FieldTypeUnknown {
+			if s, ok := GenerateValue(ft).(string); ok {
+				value = s
+			}
+		}
+		if value == "" {
+			value = gofakeit.Word()
+		}
+	}
+
+	minLength := intKeyword(schema, "minLength", 0)
+	maxLength := intKeyword(schema, "maxLength", 0)
+	// Lengths count characters, not bytes
+	for utf8.RuneCountInString(value) < minLength {
+		value += gofakeit.Letter()
+	}
+	if maxLength > 0 && utf8.RuneCountInString(value) > maxLength {
+		value = string([]rune(value)[:maxLength])
+	}
+	return value
+}
+
+// generateNumber generates a number within minimum/maximum, honouring exclusive bounds and multipleOf.
+func generateNumber(schema map[string]interface{}, integer bool) interface{} {
+	min, hasMin := floatKeyword(schema, "minimum")
+	max, hasMax := floatKeyword(schema, "maximum")
+	if exMin, ok := floatKeyword(schema, "exclusiveMinimum"); ok {
+		min, hasMin = exMin+1e-9, true
+	}
+	if exMax, ok := floatKeyword(schema, "exclusiveMaximum"); ok {
+		max, hasMax = exMax-1e-9, true
+	}
+	switch {
+	case !hasMin && !hasMax:
+		min, max = 1, 1000
+	case !hasMax:
+		max = min + 1000
+	case !hasMin:
+		min = math.Min(0, max-1000)
+	}
+	if max < min {
+		max = min
+	}
+
+	if integer {
+		lo, hi := int(math.Ceil(min)), int(math.Floor(max))
+		if hi < lo {
+			hi = lo
+		}
+		if step, ok := floatKeyword(schema, "multipleOf"); ok && step >= 1 {
+			if k, ok := multipleInRange(float64(lo), float64(hi), float64(int(step))); ok {
+				return k * int(step)
+			}
+		}
+		return gofakeit.Number(lo, hi)
+	}
+
+	if step, ok := floatKeyword(schema, "multipleOf"); ok && step > 0 {
+		if k, ok := multipleInRange(min, max, step); ok {
+			return float64(k) * step
+		}
+	}
+	return gofakeit.Float64Range(min, max)
+}
+
+// multipleInRange picks a random k with k*step within [min, max]. It reports false when no
+// multiple of step lies in the range, and the bounds win over multipleOf.
+func multipleInRange(min, max, step float64) (int, bool) {
+	lo, hi := math.Ceil(min/step), math.Floor(max/step)
+	if lo > hi || math.Abs(lo) > 1<<53 {
+		return 0, false
+	}
+	hi = math.Min(hi, lo+1_000_000) // Plenty of variety without overflowing the random range
+	return gofakeit.Number(int(lo), int(hi)), true
+}
+
+// intKeyword reads an integer schema keyword.
+func intKeyword(schema map[string]interface{}, key string, def int) int {
+	if f, ok := floatKeyword(schema, key); ok {
+		return int(f)
+	}
+	return def
+}
+
+// floatKeyword reads a numeric schema keyword. Boolean exclusive bounds (OpenAPI 3.0) are ignored.
+func floatKeyword(schema map[string]interface{}, key string) (float64, bool) {
+	switch v := schema[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// aggregateParams are the query parameters of _aggregate; they are not field filters.
+var aggregateParams = []string{"group", "count", "sum", "avg", "min", "max"}
+
+// aggregateGroup accumulates the metrics of one group.
+type aggregateGroup struct {
+	keys  []interface{}
+	count int
+	sums  map[string]float64
+	nums  map[string]int // Numeric values seen per field, for avg
+	mins  map[string]interface{}
+	maxes map[string]interface{}
+}
+
+// handleCount returns a handler with the number of items matching the list filters,
+// as {"count": n} on GET /:resource/_count and as X-Total-Count only on HEAD /:resource.
+func (e *Engine) handleCount(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		items, err := e.filterItems(c, resource)
+		if err != nil {
+			return filterErrorResponse(c, err)
+		}
+		count := len(items)
+		c.Set("X-Total-Count", strconv.Itoa(count))
+		if c.Method() == fiber.MethodHead {
+			c.Status(fiber.StatusOK)
+			return nil
+		}
+		return c.JSON(fiber.Map{"count": count})
+	}
+}
+
+// handleAggregate returns a handler computing metrics over the items matching the list filters.
+// Supports: group, count, sum, avg, min, max (comma-separated fields)
+//
+//	GET /orders/_aggregate?group=status&sum=total&avg=price&count=1
+func (e *Engine) handleAggregate(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		groupBy := splitList(c.Query("group"))
+		sums := splitList(c.Query("sum"))
+		avgs := splitList(c.Query("avg"))
+		mins := splitList(c.Query("min"))
+		maxes := splitList(c.Query("max"))
+
+		// Count is on by default unless other metrics are requested
+		withCount := len(sums)+len(avgs)+len(mins)+len(maxes) == 0
+		if raw := c.Query("count"); raw != "" {
+			v, err := strconv.ParseBool(raw)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "invalid_query",
+					"message": fmt.Sprintf("count must be a boolean, got '%s'", raw),
+				})
+			}
+			withCount = v
+		}
+
+		// Fields summed or averaged, each accumulated once
+		numeric := make([]string, 0, len(sums)+len(avgs))
+		for _, field := range append(append([]string{}, sums...), avgs...) {
+			if !containsString(numeric, field) {
+				numeric = append(numeric, field)
+			}
+		}
+
+		items, err := e.filterItems(c, resource, aggregateParams...)
+		if err != nil {
+			return filterErrorResponse(c, err)
+		}
+
+		groups := make(map[string]*aggregateGroup)
+		order := make([]string, 0)
+		for _, item := range items {
+			keys := make([]interface{}, len(groupBy))
+			parts := make([]string, len(groupBy))
+			for i, field := range groupBy {
+				keys[i] = item[field]
+				parts[i] = fmt.Sprintf("%T:%v", item[field], item[field])
+			}
+			id := strings.Join(parts, "\x00")
+			g, ok := groups[id]
+			if !ok {
+				g = &aggregateGroup{
+					keys:  keys,
+					sums:  make(map[string]float64),
+					nums:  make(map[string]int),
+					mins:  make(map[string]interface{}),
+					maxes: make(map[string]interface{}),
+				}
+				groups[id] = g
+				order = append(order, id)
+			}
+			g.add(item, numeric, mins, maxes)
+		}
+
+		// Without group the whole set is one group, even when empty
+		if len(groupBy) == 0 {
+			g := groups[""]
+			if g == nil {
+				g = &aggregateGroup{sums: map[string]float64{}, nums: map[string]int{}}
+			}
+			return c.JSON(g.result(nil, withCount, sums, avgs, mins, maxes))
+		}
+
+		sort.SliceStable(order, func(i, j int) bool {
+			ki, kj := groups[order[i]].keys, groups[order[j]].keys
+			for n := range ki {
+				if d := compareValues(ki[n], kj[n]); d != 0 {
+					return d < 0
+				}
+			}
+			return false
+		})
+		results := make([]fiber.Map, 0, len(order))
+		for _, id := range order {
+			results = append(results, groups[id].result(groupBy, withCount, sums, avgs, mins, maxes))
+		}
+		return c.JSON(results)
+	}
+}
+
+// add accumulates an item into the group.
+func (g *aggregateGroup) add(item map[string]interface{}, numeric, mins, maxes []string) {
+	g.count++
+	for _, field := range numeric {
+		if n, ok := toNumber(item[field]); ok {
+			g.sums[field] += n
+			g.nums[field]++
+		}
+	}
+	for _, field := range mins {
+		if v := item[field]; v != nil && (g.mins[field] == nil || compareValues(v, g.mins[field]) < 0) {
+			g.mins[field] = v
+		}
+	}
+	for _, field := range maxes {
+		if v := item[field]; v != nil && (g.maxes[field] == nil || compareValues(v, g.maxes[field]) > 0) {
+			g.maxes[field] = v
+		}
+	}
+}
+
+// result returns the group as a flat row: group fields, count, sum_<field>, avg_<field>, min_<field>, max_<field>.
+// Averages without any numeric value are null.
+func (g *aggregateGroup) result(groupBy []string, withCount bool, sums, avgs, mins, maxes []string) fiber.Map {
+	row := fiber.Map{}
+	for i, field := range groupBy {
+		row[field] = g.keys[i]
+	}
+	if withCount {
+		row["count"] = g.count
+	}
+	for _, field := range sums {
+		row["sum_"+field] = g.sums[field]
+	}
+	for _, field := range avgs {
+		if g.nums[field] > 0 {
+			row["avg_"+field] = g.sums[field] / float64(g.nums[field])
+		} else {
+			row["avg_"+field] = nil
+		}
+	}
+	for _, field := range mins {
+		row["min_"+field] = g.mins[field]
+	}
+	for _, field := range maxes {
+		row["max_"+field] = g.maxes[field]
+	}
+	return row
+}
+
+// distinctValues returns the distinct values of a field, sorted; items without the field are skipped.
+func distinctValues(items []map[string]interface{}, field string) []interface{} {
+	seen := make(map[string]bool)
+	values := make([]interface{}, 0)
+	for _, item := range items {
+		v, ok := item[field]
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%T:%v", v, v)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		values = append(values, v)
+	}
+	sort.SliceStable(values, func(i, j int) bool { return compareValues(values[i], values[j]) < 0 })
+	return values
+}
+
+// compareValues orders two field values: numbers numerically, anything else by its text.
+// nil sorts first.
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// toNumber converts a numeric field value to float64.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,417 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// referencePattern matches references to earlier batch results: $0.id or $2.customer.email, the
+// delimited form ${0.id} for references followed by more name characters, and the escapes $$1 and
+// $${ for a literal $1 or ${. Undelimited field names start with a letter, so "$5.99" is plain text.
+var referencePattern = regexp.MustCompile(`\$\$[\d{]|\$\{(\d+)((?:\.[A-Za-z0-9_-]+)*)\}|\$(\d+)((?:\.[A-Za-z_][A-Za-z0-9_-]*)*)`)
+
+// batchRequest is one sub-request of POST /_batch.
+type batchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"` // Content-Type and If-Match, as on the item routes
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// header returns a header of a sub-request, matching its name case-insensitively.
+func (req batchRequest) header(name string) string {
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// batchResult is the response to one sub-request.
+type batchResult struct {
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// batchTx applies sub-requests to the store under one lock and can undo them.
+type batchTx struct {
+	e        *Engine
+	snapshot map[string][]map[string]interface{} // Resources as they were before their first change
+	counters map[string]int64                    // Autoincrement counters before the batch
+	changes  map[string]int                      // Changes per resource/id, not yet in the item versions
+	events   []ChangeEvent                       // Published only on commit
+}
+
+// batchFailure stops a batch with the response of the failing sub-request.
+type batchFailure struct {
+	status int
+	body   fiber.Map
+}
+
+// handleBatch runs a list of sub-requests against the store atomically. Strings in paths and
+// bodies may reference earlier results ($0.id); if any sub-request fails, every change is rolled back.
+//
+//	POST /_batch
+//	[{"method": "POST", "path": "/orders", "body": {"total": 30}},
+//	 {"method": "POST", "path": "/items", "body": {"orderId": "$0.id", "sku": "A1"}}]
+func (e *Engine) handleBatch(c *fiber.Ctx) error {
+	var requests []batchRequest
+	if err := json.Unmarshal(c.Body(), &requests); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_body",
+			"message": "Request body must be a JSON array of {method, path, body} sub-requests",
+		})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tx := &batchTx{e: e, snapshot: make(map[string][]map[string]interface{}), counters: e.ids.counters(), changes: make(map[string]int)}
+	results := make([]batchResult, 0, len(requests))
+	for i, req := range requests {
+		result, failure := tx.apply(req, results)
+		if failure != nil {
+			tx.rollback()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "batch_failed",
+				"message": fmt.Sprintf("Request %d (%s %s) failed with %d; all changes were rolled back",
+					i, strings.ToUpper(req.Method), req.Path, failure.status),
+				"details": fiber.Map{"index": i, "status": failure.status, "body": failure.body},
+			})
+		}
+		results = append(results, result)
+	}
+
+	for _, event := range tx.events {
+		e.publish(event.Type, event.Resource, event.Item)
+	}
+	return c.JSON(fiber.Map{"results": results})
+}
+
+// apply runs one sub-request after resolving its references to earlier results.
+func (tx *batchTx) apply(req batchRequest, results []batchResult) (batchResult, *batchFailure) {
+	resolvedPath, err := resolveReferences(req.Path, results)
+	if err != nil {
+		return batchResult{}, batchError(fiber.StatusBadRequest, "invalid_reference", err.Error())
+	}
+	body, err := resolveReferences(req.Body, results)
+	if err != nil {
+		return batchResult{}, batchError(fiber.StatusBadRequest, "invalid_reference", err.Error())
+	}
+
+	path, _, _ := strings.Cut(fmt.Sprintf("%v", resolvedPath), "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	resource := parts[0]
+	if len(parts) > 2 || resource == "" {
+		return batchResult{}, batchError(fiber.StatusNotFound, "not_found", fmt.Sprintf("No resource route matches '%s'", path))
+	}
+	if _, ok := tx.e.store[resource]; !ok {
+		return batchResult{}, batchError(fiber.StatusNotFound, "not_found", fmt.Sprintf("Resource '%s' not found", resource))
+	}
+	if tx.e.isProxied(resource) {
+		return batchResult{}, batchError(fiber.StatusBadRequest, "not_supported", fmt.Sprintf("%s is proxied and cannot be batched", resource))
+	}
+
+	method := strings.ToUpper(req.Method)
+	var hook *writeHook
+	if action := batchAction(method, len(parts) == 2); action != "" {
+		request := hookRequest{Method: method, Path: path, Resource: resource, Action: action, Headers: req.Headers, Body: body}
+		if len(parts) == 2 {
+			request.Params = map[string]string{"id": parts[1]}
+		}
+		if hook = tx.e.hookWrite(request); hook != nil {
+			hook.tx = tx
+		}
+	}
+	body, res, err := hook.before(body)
+	if err != nil {
+		return batchResult{}, &batchFailure{status: fiber.StatusInternalServerError, body: scriptErrorBody(err)}
+	}
+	if res != nil {
+		return hookBatchResult(res)
+	}
+
+	result, failure := tx.route(method, resource, parts, req, body)
+	if failure != nil {
+		return batchResult{}, failure
+	}
+	after, err := hook.after(result.Status, result.Body)
+	if err != nil {
+		return batchResult{}, &batchFailure{status: fiber.StatusInternalServerError, body: scriptErrorBody(err)}
+	}
+	return batchResult{Status: after.status, Body: after.body}, nil
+}
+
+// route applies a sub-request to the store as the routes of its resource would.
+func (tx *batchTx) route(method, resource string, parts []string, req batchRequest, body interface{}) (batchResult, *batchFailure) {
+	items := tx.e.store[resource]
+	if len(parts) == 1 {
+		switch method {
+		case fiber.MethodGet:
+			// A snapshot, so later sub-requests and a rollback leave this result as it was
+			list := make([]interface{}, len(items))
+			for i, item := range items {
+				list[i] = maps.Clone(item)
+			}
+			return batchResult{Status: fiber.StatusOK, Body: list}, nil
+		case fiber.MethodPost:
+			item, ok := body.(map[string]interface{})
+			if !ok {
+				return batchResult{}, batchError(fiber.StatusBadRequest, "invalid_body", "Request body must be a JSON object")
+			}
+			field := tx.e.ids.field(resource)
+			if _, hasID := item[field]; hasID && tx.find(resource, idString(item[field])) >= 0 {
+				return batchResult{}, batchError(fiber.StatusConflict, "conflict",
+					fmt.Sprintf("%s with id '%s' already exists", resource, idString(item[field])))
+			}
+			tx.e.ids.assign(resource, item, tx.e.takenIDs(resource))
+			tx.save(resource)
+			tx.e.store[resource] = append(tx.e.store[resource], item)
+			tx.record(EventCreated, resource, item)
+			return batchResult{Status: fiber.StatusCreated, Body: maps.Clone(item)}, nil
+		}
+		return batchResult{}, batchError(fiber.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("%s is not allowed on /%s", method, resource))
+	}
+
+	id := parts[1]
+	i := tx.find(resource, id)
+	if i < 0 {
+		return batchResult{}, batchError(fiber.StatusNotFound, "not_found", fmt.Sprintf("%s with id '%s' not found", resource, id))
+	}
+	if method == fiber.MethodPut || method == fiber.MethodPatch || method == fiber.MethodDelete {
+		if etag := tx.etag(resource, id); ifMatchFails(req.header(fiber.HeaderIfMatch), etag) {
+			return batchResult{}, batchError(fiber.StatusPreconditionFailed, "precondition_failed",
+				preconditionMessage(resource, id, etag, req.header(fiber.HeaderIfMatch)))
+		}
+	}
+	field := tx.e.ids.field(resource)
+	switch method {
+	case fiber.MethodGet:
+		return batchResult{Status: fiber.StatusOK, Body: maps.Clone(items[i])}, nil
+	case fiber.MethodPut:
+		item, ok := body.(map[string]interface{})
+		if !ok {
+			return batchResult{}, batchError(fiber.StatusBadRequest, "invalid_body", "Request body must be a JSON object")
+		}
+		item[field] = tx.e.store[resource][i][field]
+		tx.save(resource)
+		tx.e.store[resource][i] = item
+		tx.record(EventUpdated, resource, item)
+		return batchResult{Status: fiber.StatusOK, Body: maps.Clone(item)}, nil
+	case fiber.MethodPatch:
+		// Same formats as PATCH /:resource/:id, chosen by the sub-request's Content-Type
+		raw, _ := json.Marshal(body)
+		patch, perr := parseItemPatch(req.header(fiber.HeaderContentType), raw)
+		if perr != nil {
+			return batchResult{}, batchError(perr.status, perr.code, perr.message)
+		}
+		item, perr := patch.apply(tx.e.store[resource][i], field)
+		if perr != nil {
+			return batchResult{}, batchError(perr.status, perr.code, perr.message)
+		}
+		tx.save(resource)
+		tx.e.store[resource][i] = item
+		tx.record(EventUpdated, resource, item)
+		return batchResult{Status: fiber.StatusOK, Body: maps.Clone(item)}, nil
+	case fiber.MethodDelete:
+		tx.save(resource)
+		item := tx.e.store[resource][i]
+		tx.e.store[resource] = append(tx.e.store[resource][:i:i], tx.e.store[resource][i+1:]...)
+		tx.record(EventDeleted, resource, item)
+		return batchResult{Status: fiber.StatusNoContent}, nil
+	}
+	return batchResult{}, batchError(fiber.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("%s is not allowed on /%s/:id", method, resource))
+}
+
+// batchAction returns the script hook action of a sub-request, or "" when no route matches it.
+func batchAction(method string, hasID bool) string {
+	switch {
+	case method == fiber.MethodGet && !hasID:
+		return "list"
+	case method == fiber.MethodPost && !hasID:
+		return "create"
+	case method == fiber.MethodGet && hasID:
+		return "get"
+	case method == fiber.MethodPut && hasID:
+		return "update"
+	case method == fiber.MethodPatch && hasID:
+		return "patch"
+	case method == fiber.MethodDelete && hasID:
+		return "delete"
+	}
+	return ""
+}
+
+// hookBatchResult is the result of a sub-request that a before hook answered itself.
+// An error status fails the batch.
+func hookBatchResult(res *hookResponse) (batchResult, *batchFailure) {
+	if res.status < fiber.StatusBadRequest {
+		return batchResult{Status: res.status, Body: res.body}, nil
+	}
+	if body, ok := res.body.(map[string]interface{}); ok {
+		return batchResult{}, &batchFailure{status: res.status, body: body}
+	}
+	code, message := res.errorFields()
+	return batchResult{}, batchError(res.status, code, message)
+}
+
+// find returns the position of an item in a resource, or -1.
+func (tx *batchTx) find(resource, id string) int {
+	for i, item := range tx.e.store[resource] {
+		if tx.e.ids.id(resource, item) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save snapshots a resource before its first change in the batch. A resource that does not
+// exist yet, which only script hooks can write to, is snapshotted as nil.
+func (tx *batchTx) save(resource string) {
+	if _, saved := tx.snapshot[resource]; saved {
+		return
+	}
+	if _, exists := tx.e.store[resource]; !exists {
+		tx.snapshot[resource] = nil
+		return
+	}
+	items := make([]map[string]interface{}, len(tx.e.store[resource]))
+	for i, item := range tx.e.store[resource] {
+		items[i] = maps.Clone(item)
+	}
+	tx.snapshot[resource] = items
+}
+
+// record queues the change event of an item, as it is now.
+func (tx *batchTx) record(eventType, resource string, item map[string]interface{}) {
+	tx.changes[resource+"/"+tx.e.ids.id(resource, item)]++
+	tx.events = append(tx.events, ChangeEvent{Type: eventType, Resource: resource, Item: maps.Clone(item)})
+}
+
+// etag returns the ETag an item will have once the batch commits, counting its earlier changes in the batch.
+func (tx *batchTx) etag(resource, id string) string {
+	v := tx.e.versions.item(resource, id)
+	if changes := tx.changes[resource+"/"+id]; changes > 0 {
+		v.version = tx.e.versions.items[resource][id].version + changes
+	}
+	return v.etag()
+}
+
+// rollback restores every changed resource and the id counters, and drops the queued events.
+func (tx *batchTx) rollback() {
+	for resource, items := range tx.snapshot {
+		if items == nil {
+			delete(tx.e.store, resource)
+			continue
+		}
+		tx.e.store[resource] = items
+	}
+	tx.e.ids.restore(tx.counters)
+	tx.events = nil
+}
+
+// batchError builds the failure of a sub-request.
+func batchError(status int, code, message string) *batchFailure {
+	return &batchFailure{status: status, body: fiber.Map{"error": code, "message": message}}
+}
+
+// resolveReferences replaces $N.path and ${N.path} references in the strings of a value with fields
+// of earlier results. A string that is exactly one reference takes the referenced value with its
+// type; inside longer text, a bare $N without a field is left alone ("Save $5 today").
+func resolveReferences(value interface{}, results []batchResult) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			resolved, err := resolveReferences(val, results)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			resolved, err := resolveReferences(val, results)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case string:
+		if m := referencePattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			if ref, ok := parseReference(m); ok {
+				return lookupReference(ref, results)
+			}
+		}
+		var err error
+		out := referencePattern.ReplaceAllStringFunc(v, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			ref, _ := parseReference(referencePattern.FindStringSubmatch(match))
+			if !ref.delimited && ref.path == "" {
+				return match
+			}
+			resolved, lookupErr := lookupReference(ref, results)
+			if lookupErr != nil {
+				err = lookupErr
+				return match
+			}
+			return idString(resolved)
+		})
+		return out, err
+	}
+	return value, nil
+}
+
+// batchReference is a reference to a field of an earlier batch result.
+type batchReference struct {
+	text      string // As written, for error messages
+	index     string
+	path      string // Dot-separated fields, with a leading dot
+	delimited bool   // Written as ${N.path}
+}
+
+// parseReference reads a referencePattern match; it reports false for escapes.
+func parseReference(m []string) (batchReference, bool) {
+	switch {
+	case m[1] != "":
+		return batchReference{text: m[0], index: m[1], path: m[2], delimited: true}, true
+	case m[3] != "":
+		return batchReference{text: m[0], index: m[3], path: m[4]}, true
+	}
+	return batchReference{}, false
+}
+
+// lookupReference returns the value of a reference to an earlier result.
+func lookupReference(ref batchReference, results []batchResult) (interface{}, error) {
+	index, err := strconv.Atoi(ref.index)
+	if err != nil || index >= len(results) {
+		return nil, fmt.Errorf("%s refers to a request that has not run yet (write $$ for a literal $)", ref.text)
+	}
+
+	value := results[index].Body
+	for _, field := range strings.Split(strings.TrimPrefix(ref.path, "."), ".") {
+		if field == "" {
+			break
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: result %d has no field '%s'", ref.text, index, field)
+		}
+		if value, ok = obj[field]; !ok {
+			return nil, fmt.Errorf("%s: result %d has no field '%s'", ref.text, index, field)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	results := []batchResult{
+		{Status: 201, Body: map[string]interface{}{"id": float64(7), "customer": map[string]interface{}{"email": "a@b.c"}}},
+	}
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "whole string keeps type", value: "$0.id", want: float64(7)},
+		{name: "nested field", value: "$0.customer.email", want: "a@b.c"},
+		{name: "embedded in path", value: "/users/$0.id", want: "/users/7"},
+		{name: "delimited", value: "${0.id}x", want: "7x"},
+		{name: "delimited whole string", value: "${0.id}", want: float64(7)},
+		{name: "bare index in text", value: "Save $5 today", want: "Save $5 today"},
+		{name: "price in text", value: "Costs $5.99", want: "Costs $5.99"},
+		{name: "escape", value: "$$0.id and $${0.id}", want: "$0.id and ${0.id}"},
+		{name: "nested values", value: map[string]interface{}{"ids": []interface{}{"$0.id"}}, want: map[string]interface{}{"ids": []interface{}{float64(7)}}},
+		{name: "request not run", value: "$1.id", wantErr: true},
+		{name: "missing field", value: "$0.name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveReferences(tt.value, results)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("resolveReferences(%v) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveReferences(%v): %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveReferences(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchReferencesEarlierResults(t *testing.T) {
+	e := NewEngine(testData(t, `{"users":[{"id":1,"name":"a"}]}`))
+	status, body := testRequest(t, e, "POST", "/_batch", "application/json",
+		`[{"method":"POST","path":"/users","body":{"name":"b"}},{"method":"GET","path":"/users/$0.id"}]`)
+	if status != 200 {
+		t.Fatalf("status = %d, body %v", status, body)
+	}
+	results := body.(map[string]interface{})["results"].([]interface{})
+	created := results[0].(map[string]interface{})["body"].(map[string]interface{})
+	fetched := results[1].(map[string]interface{})["body"].(map[string]interface{})
+	if fetched["id"] != created["id"] || fetched["name"] != "b" {
+		t.Errorf("GET /users/$0.id = %v, want the created item %v", fetched, created)
+	}
+}
+
+func TestBatchListResultIsSnapshot(t *testing.T) {
+	e := NewEngine(testData(t, `{"users":[{"id":1,"name":"a"}]}`))
+	status, body := testRequest(t, e, "POST", "/_batch", "application/json",
+		`[{"method":"GET","path":"/users"},{"method":"PATCH","path":"/users/1","body":{"name":"zzz"}}]`)
+	if status != 200 {
+		t.Fatalf("status = %d, body %v", status, body)
+	}
+	results := body.(map[string]interface{})["results"].([]interface{})
+	listed := results[0].(map[string]interface{})["body"].([]interface{})
+	if name := listed[0].(map[string]interface{})["name"]; name != "a" {
+		t.Errorf("listed name = %v, want the value before the later PATCH (a)", name)
+	}
+}
+
+func TestBatchRollback(t *testing.T) {
+	src := `{"users":[{"id":1,"name":"a"},{"id":2,"name":"b"}],"posts":[{"id":1,"userId":1}]}`
+	config := EngineConfig{IDs: IDStrategies{"*": {Strategy: IDAutoIncrement}}}
+	e := NewEngineWithConfig(testData(t, src), config)
+	status, body := testRequest(t, e, "POST", "/_batch", "application/json", `[
+		{"method":"POST","path":"/users","body":{"name":"c"}},
+		{"method":"PATCH","path":"/users/1","body":{"name":"x"}},
+		{"method":"DELETE","path":"/users/2"},
+		{"method":"POST","path":"/posts","body":{"userId":"$0.id"}},
+		{"method":"GET","path":"/users/$3.missing"}
+	]`)
+	if status != 400 {
+		t.Fatalf("status = %d, body %v, want 400", status, body)
+	}
+	details := body.(map[string]interface{})["details"].(map[string]interface{})
+	if details["index"] != float64(4) {
+		t.Errorf("failed index = %v, want 4", details["index"])
+	}
+
+	want := NewEngineWithConfig(testData(t, src), config).GetStore()
+	if got := e.GetStore(); !reflect.DeepEqual(got, want) {
+		t.Errorf("store after rollback = %v, want %v", got, want)
+	}
+
+	// The id counter is restored too, so the next item gets the id the rolled back one had
+	if status, body := testRequest(t, e, "POST", "/users", "application/json", `{"name":"c"}`); status != 201 || body.(map[string]interface{})["id"] != float64(3) {
+		t.Errorf("POST /users after rollback = %d %v, want id 3", status, body)
+	}
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// bulkResult is the outcome of one item of a bulk operation.
+type bulkResult struct {
+	Index   *int                   `json:"index,omitempty"` // Position in a _bulk request body
+	ID      interface{}            `json:"id,omitempty"`
+	Status  int                    `json:"status"`
+	Item    map[string]interface{} `json:"item,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Message string                 `json:"message,omitempty"`
+}
+
+// handleBulkCreate returns a handler that creates an array of items under a single store lock.
+// Items that are not objects or reuse an existing id fail on their own; with ?_atomic=true
+// any failure rejects the whole batch.
+func (e *Engine) handleBulkCreate(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body []interface{}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_body",
+				"message": "Request body must be a JSON array of items",
+			})
+		}
+		atomic := c.QueryBool("_atomic")
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		field := e.ids.field(resource)
+		ids := e.takenIDs(resource)
+		counters := e.ids.counters()
+
+		request := e.bulkHookRequest(c, resource, "create")
+		results := make([]bulkResult, len(body))
+		hooks := make([]*writeHook, len(body))
+		created := make([]int, 0, len(body))
+		failures := make([]bulkResult, 0)
+		for i, raw := range body {
+			index := i
+			request.Body = raw
+			hooks[i] = e.hookWrite(request)
+			value, res, err := hooks[i].before(raw)
+			if err != nil {
+				e.ids.restore(counters)
+				return sendScriptError(c, err)
+			}
+			if res != nil {
+				results[i] = hookBulkResult(&index, nil, res)
+				if res.status >= fiber.StatusBadRequest {
+					failures = append(failures, results[i])
+				}
+				continue
+			}
+			item, ok := value.(map[string]interface{})
+			if !ok {
+				results[i] = bulkResult{Index: &index, Status: fiber.StatusBadRequest, Error: "invalid_item", Message: "Item must be a JSON object"}
+				failures = append(failures, results[i])
+				continue
+			}
+			if _, hasID := item[field]; hasID && ids[idString(item[field])] {
+				results[i] = bulkResult{Index: &index, ID: item[field], Status: fiber.StatusConflict, Error: "conflict",
+					Message: fmt.Sprintf("%s with id '%s' already exists", resource, idString(item[field]))}
+				failures = append(failures, results[i])
+				continue
+			}
+			e.ids.assign(resource, item, ids)
+			ids[idString(item[field])] = true
+			results[i] = bulkResult{Index: &index, ID: item[field], Status: fiber.StatusCreated, Item: item}
+			created = append(created, i)
+		}
+
+		if atomic && len(failures) > 0 {
+			e.ids.restore(counters)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bulk_failed",
+				"message": fmt.Sprintf("%d of %d items failed; nothing was created", len(failures), len(body)),
+				"details": failures,
+			})
+		}
+
+		for _, i := range created {
+			item := results[i].Item
+			e.store[resource] = append(e.store[resource], item)
+			e.publish(EventCreated, resource, item)
+		}
+		for _, i := range created {
+			if !hookBulkAfter(hooks[i], &results[i]) {
+				failures = append(failures, results[i])
+			}
+		}
+
+		status := fiber.StatusCreated
+		if len(failures) > 0 {
+			status = fiber.StatusMultiStatus
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"created": len(created),
+			"failed":  len(failures),
+			"results": results,
+		})
+	}
+}
+
+// handleBulkPatch returns a handler that patches every item matching the filters of the
+// request (PATCH /users?role=guest), under a single store lock. The body is dispatched on its
+// Content-Type like a single-item PATCH; items the patch cannot apply to fail on their own,
+// and with ?_atomic=true any failure leaves every item unchanged.
+func (e *Engine) handleBulkPatch(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		patch, perr := parseItemPatch(c.Get(fiber.HeaderContentType), c.Body())
+		if perr != nil {
+			return perr.send(c)
+		}
+		if !hasBulkFilter(c) {
+			return filterRequired(c)
+		}
+		atomic := c.QueryBool("_atomic")
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		matched, err := e.matchedIDs(c, resource)
+		if err != nil {
+			return filterErrorResponse(c, err)
+		}
+
+		field := e.ids.field(resource)
+		request := e.bulkHookRequest(c, resource, "patch")
+		request.Body, _ = parseJSONBody(c.Body())
+		results := make([]bulkResult, 0, len(matched))
+		hooks := make(map[int]*writeHook, len(matched))
+		patched := make(map[int]map[string]interface{}, len(matched))
+		failures := make([]bulkResult, 0)
+		for i, item := range e.store[resource] {
+			if !matched[idString(item[field])] {
+				continue
+			}
+			fail := func(perr *patchError) {
+				results = append(results, bulkResult{ID: item[field], Status: perr.status, Error: perr.code, Message: perr.message})
+				failures = append(failures, results[len(results)-1])
+			}
+			request.Params = map[string]string{"id": idString(item[field])}
+			hook := e.hookWrite(request)
+			p := patch
+			if hook != nil {
+				body, res, err := hook.before(request.Body)
+				if err != nil {
+					return sendScriptError(c, err)
+				}
+				if res != nil {
+					results = append(results, hookBulkResult(nil, item[field], res))
+					if res.status >= fiber.StatusBadRequest {
+						failures = append(failures, results[len(results)-1])
+					}
+					continue
+				}
+				// The hook may have changed the patch for this item
+				raw, _ := json.Marshal(body)
+				if p, perr = parseItemPatch(c.Get(fiber.HeaderContentType), raw); perr != nil {
+					fail(perr)
+					continue
+				}
+			}
+			out, perr := p.apply(item, field)
+			if perr != nil {
+				fail(perr)
+				continue
+			}
+			patched[i] = out
+			hooks[len(results)] = hook
+			results = append(results, bulkResult{ID: item[field], Status: fiber.StatusOK, Item: out})
+		}
+
+		if atomic && len(failures) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bulk_failed",
+				"message": fmt.Sprintf("%d of %d items failed; nothing was updated", len(failures), len(results)),
+				"details": failures,
+			})
+		}
+
+		for i := range e.store[resource] {
+			if out, ok := patched[i]; ok {
+				e.store[resource][i] = out
+				e.publish(EventUpdated, resource, out)
+			}
+		}
+		for i, hook := range hooks {
+			if !hookBulkAfter(hook, &results[i]) {
+				failures = append(failures, results[i])
+			}
+		}
+
+		status := fiber.StatusOK
+		if len(failures) > 0 {
+			status = fiber.StatusMultiStatus
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"updated": len(patched),
+			"failed":  len(failures),
+			"results": results,
+		})
+	}
+}
+
+// handleBulkDelete returns a handler that deletes every item matching the filters of the
+// request (DELETE /users?active=false), under a single store lock.
+func (e *Engine) handleBulkDelete(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !hasBulkFilter(c) {
+			return filterRequired(c)
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		matched, err := e.matchedIDs(c, resource)
+		if err != nil {
+			return filterErrorResponse(c, err)
+		}
+
+		field := e.ids.field(resource)
+		request := e.bulkHookRequest(c, resource, "delete")
+		results := make([]bulkResult, 0, len(matched))
+		hooks := make(map[int]*writeHook, len(matched))
+		kept := make([]map[string]interface{}, 0, len(e.store[resource]))
+		deleted := make([]map[string]interface{}, 0, len(matched))
+		for _, item := range e.store[resource] {
+			if !matched[idString(item[field])] {
+				kept = append(kept, item)
+				continue
+			}
+			request.Params = map[string]string{"id": idString(item[field])}
+			hook := e.hookWrite(request)
+			if _, res, err := hook.before(nil); err != nil {
+				return sendScriptError(c, err)
+			} else if res != nil {
+				kept = append(kept, item)
+				results = append(results, hookBulkResult(nil, item[field], res))
+				continue
+			}
+			hooks[len(results)] = hook
+			deleted = append(deleted, item)
+			results = append(results, bulkResult{ID: item[field], Status: fiber.StatusNoContent})
+		}
+		e.store[resource] = kept
+		for _, item := range deleted {
+			e.publish(EventDeleted, resource, item)
+		}
+		for i, hook := range hooks {
+			hookBulkAfter(hook, &results[i])
+		}
+
+		status := fiber.StatusOK
+		for _, result := range results {
+			if result.Status >= fiber.StatusBadRequest {
+				status = fiber.StatusMultiStatus
+			}
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"deleted": len(deleted),
+			"results": results,
+		})
+	}
+}
+
+// bulkHookRequest returns the request that the hooks of each item of a bulk request see.
+func (e *Engine) bulkHookRequest(c *fiber.Ctx, resource, action string) hookRequest {
+	return hookRequest{
+		Method:   c.Method(),
+		Path:     c.Path(),
+		Resource: resource,
+		Action:   action,
+		Query:    c.Queries(),
+		Headers:  requestHeaders(c),
+	}
+}
+
+// hookBulkResult is the result of a bulk item that a before hook answered itself; the item
+// was left alone, and fails when the status is an error.
+func hookBulkResult(index *int, id interface{}, res *hookResponse) bulkResult {
+	result := bulkResult{Index: index, ID: id, Status: res.status}
+	if res.status >= fiber.StatusBadRequest {
+		result.Error, result.Message = res.errorFields()
+	} else {
+		result.Item, _ = res.body.(map[string]interface{})
+	}
+	return result
+}
+
+// hookBulkAfter runs the after hook of a written bulk item on its result, and reports
+// whether it succeeded. The write is already committed when the hook fails, so the failure
+// is reported on the item alone (500 script_error) instead of failing the whole request.
+func hookBulkAfter(hook *writeHook, result *bulkResult) bool {
+	res, err := hook.after(result.Status, result.Item)
+	if err != nil {
+		body := scriptErrorBody(err)
+		result.Status = fiber.StatusInternalServerError
+		result.Error, result.Message = body["error"].(string), body["message"].(string)
+		return false
+	}
+	result.Status = res.status
+	result.Item, _ = res.body.(map[string]interface{})
+	return true
+}
+
+// matchedIDs returns the ids of the items matching the filters of a request.
+// It must be called with the store lock held.
+func (e *Engine) matchedIDs(c *fiber.Ctx, resource string) (map[string]bool, error) {
+	items := make([]map[string]interface{}, len(e.store[resource]))
+	copy(items, e.store[resource])
+	items, err := e.applyFilters(c, resource, items)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(items))
+	for _, item := range items {
+		ids[e.ids.id(resource, item)] = true
+	}
+	return ids, nil
+}
+
+// hasBulkFilter reports whether a filter-based bulk request has a filter, or ?_all=true
+// to confirm that every item is meant. A q without searchable words is no filter.
+func hasBulkFilter(c *fiber.Ctx) bool {
+	if c.QueryBool("_all") || len(tokenize(c.Query("q"))) > 0 || c.Query("_filter") != "" {
+		return true
+	}
+	for key := range c.Queries() {
+		if isFieldFilter(key, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRequired answers a filter-based bulk request without a filter.
+func filterRequired(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "filter_required",
+		"message": "Add a filter (?field=value, q or _filter), or _all=true to apply to every item",
+	})
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultCursorLimit is the page size of cursor pagination without _limit.
+const defaultCursorLimit = 10
+
+// pageCursor is the decoded form of an opaque _cursor token. It records the sort keys
+// of the item at the page boundary, so pages stay stable when items are inserted or removed.
+type pageCursor struct {
+	Sort   string `json:"s,omitempty"` // Active _sort field
+	Rank   bool   `json:"r,omitempty"` // Items are in search relevance order; Value is the score
+	Order  string `json:"o"`           // Active _order
+	Value  string `json:"v,omitempty"` // Sort field value of the boundary item
+	ID     string `json:"i"`           // Id of the boundary item, the tie-breaker
+	Before bool   `json:"b,omitempty"` // Page ends before the boundary (prev) instead of after it (next)
+}
+
+// encode returns the opaque token of a cursor.
+func (pc pageCursor) encode() string {
+	data, _ := json.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a _cursor token and checks that it was issued for the active sort.
+func decodeCursor(token, sortField, order string, ranked bool) (pageCursor, error) {
+	var pc pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &pc) != nil {
+		return pc, fmt.Errorf("Cursor is malformed")
+	}
+	if pc.Sort != sortField || pc.Order != order || pc.Rank != ranked {
+		return pc, fmt.Errorf("Cursor was issued for another _sort/_order; restart without a cursor")
+	}
+	return pc, nil
+}
+
+// cursorPage sorts items by the active sort field with the id field as tie-breaker and returns the
+// page after (or before) the cursor, with the cursors of the next and previous pages.
+// An empty token returns the first page. With search scores and no sort field the items keep
+// their relevance order, best first, and the cursor records the score of the boundary item.
+func cursorPage(items []map[string]interface{}, idField, sortField, order, token string, limit int, scores map[string]float64) (page []map[string]interface{}, next, prev string, err error) {
+	if limit <= 0 {
+		limit = defaultCursorLimit
+	}
+	ranked := sortField == "" && scores != nil
+
+	key := func(item map[string]interface{}) (string, string) {
+		id := idString(item[idField])
+		value := ""
+		if ranked {
+			value = strconv.FormatFloat(scores[id], 'g', -1, 64)
+		} else if sortField != "" {
+			value = fmt.Sprintf("%v", item[sortField])
+		}
+		return value, id
+	}
+	compare := func(value, id, otherValue, otherID string) int {
+		var c int
+		if ranked {
+			score, _ := strconv.ParseFloat(value, 64)
+			otherScore, _ := strconv.ParseFloat(otherValue, 64)
+			c = cmp.Compare(otherScore, score)
+		} else {
+			c = strings.Compare(value, otherValue)
+		}
+		if c == 0 {
+			c = strings.Compare(id, otherID)
+		}
+		if order == "desc" && !ranked {
+			return -c
+		}
+		return c
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		vi, idi := key(items[i])
+		vj, idj := key(items[j])
+		return compare(vi, idi, vj, idj) < 0
+	})
+
+	start, end := 0, min(limit, len(items))
+	if token != "" {
+		pc, err := decodeCursor(token, sortField, order, ranked)
+		if err != nil {
+			return nil, "", "", err
+		}
+		// First item strictly after the boundary
+		after := sort.Search(len(items), func(i int) bool {
+			v, id := key(items[i])
+			return compare(v, id, pc.Value, pc.ID) > 0
+		})
+		if pc.Before {
+			// First item at or after the boundary
+			end = sort.Search(len(items), func(i int) bool {
+				v, id := key(items[i])
+				return compare(v, id, pc.Value, pc.ID) >= 0
+			})
+			start = max(end-limit, 0)
+		} else {
+			start = after
+			end = min(start+limit, len(items))
+		}
+	}
+
+	boundary := func(item map[string]interface{}, before bool) string {
+		v, id := key(item)
+		pc := pageCursor{Sort: sortField, Rank: ranked, Order: order, ID: id, Before: before}
+		if sortField != "" || ranked {
+			pc.Value = v
+		}
+		return pc.encode()
+	}
+	if end < len(items) && end > 0 {
+		next = boundary(items[end-1], false)
+	}
+	if start > 0 && start < len(items) {
+		prev = boundary(items[start], true)
+	}
+	return items[start:end], next, prev, nil
+}
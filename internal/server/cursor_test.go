@@ -0,0 +1,38 @@
+package server
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCursorPageKeepsRelevanceOrder(t *testing.T) {
+	items := []map[string]interface{}{
+		{"id": "a"}, {"id": "b"}, {"id": "c"}, {"id": "d"},
+	}
+	scores := map[string]float64{"a": 0.5, "b": 2, "c": 1, "d": 1}
+	want := []string{"b", "c", "d", "a"}
+
+	var got []string
+	token := ""
+	for range want {
+		page, next, _, err := cursorPage(items, "id", "", "asc", token, 1, scores)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page {
+			got = append(got, item["id"].(string))
+		}
+		if token = next; token == "" {
+			break
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	// A ranked cursor is refused without the search, and the other way round
+	_, next, _, _ := cursorPage(items, "id", "", "asc", "", 1, scores)
+	if _, _, _, err := cursorPage(items, "id", "", "asc", next, 1, nil); err == nil {
+		t.Error("ranked cursor accepted without search scores")
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
 )
 
 // RequestLog represents a logged API request for TUI display.
@@ -25,17 +25,37 @@ type RequestLog struct {
 
 // Engine holds the Fiber app and in-memory data store.
 type Engine struct {
-	app       *fiber.App
-	store     map[string][]map[string]interface{}
-	mu        sync.RWMutex
-	OnRequest func(log RequestLog) // Callback for TUI logging
+	app           *fiber.App
+	store         map[string][]map[string]interface{}
+	mu            sync.RWMutex
+	stubs         []Stub
+	scenarios     *scenarioStore
+	scripts       map[string]*Script
+	proxy         *ProxyConfig
+	spec          *Spec
+	events        *eventHub
+	search        *searchIndexes
+	versions      *versionStore
+	ids           *idGenerator
+	format        string
+	envelope      *Envelope
+	graphQLSchema *graphql.Schema
+	graphQLErr    error
+	OnRequest     func(log RequestLog) // Callback for TUI logging
 }
 
 // EngineConfig holds configuration options for the engine.
 type EngineConfig struct {
 	EnableLogger bool
 	ChaosMode    bool
-	ChaosPercent int // Percentage of requests to fail (0-100)
+	ChaosPercent int                // Percentage of requests to fail (0-100)
+	Stubs        []Stub             // Request-matching stubs, as returned by LoadStubs
+	Scripts      map[string]*Script // Resource hooks, as returned by LoadScripts
+	Proxy        *ProxyConfig       // Upstream for unknown routes (nil disables)
+	Spec         *Spec              // OpenAPI operations to mock, as returned by LoadSpec
+	Format       string             // Default response format: FormatJSON, FormatJSONAPI or FormatHAL
+	Envelope     *Envelope          // Wrapper for plain JSON responses (nil returns bare values)
+	IDs          IDStrategies       // Id field and generation per resource, as returned by ParseIDFlags
 }
 
 // NewEngine creates a new Engine instance with dynamic routes based on the provided data.
@@ -50,14 +70,26 @@ func NewEngineWithConfig(data map[string]interface{}, config EngineConfig) *Engi
 			AppName:               "Insta-Mock",
 			DisableStartupMessage: true,
 		}),
-		store: make(map[string][]map[string]interface{}),
+		store:     make(map[string][]map[string]interface{}),
+		stubs:     config.Stubs,
+		scenarios: newScenarioStore(config.Stubs),
+		scripts:   config.Scripts,
+		proxy:     config.Proxy,
+		spec:      config.Spec,
+		events:    newEventHub(),
+		search:    newSearchIndexes(),
+		versions:  newVersionStore(),
+		ids:       newIDGenerator(config.IDs),
+		format:    config.Format,
+		envelope:  config.Envelope,
 	}
 
 	// Enable CORS for all origins
 	e.app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,If-Match,If-None-Match,If-Modified-Since",
+		ExposeHeaders: "X-Total-Count,X-Page,X-Limit,X-Next-Cursor,X-Prev-Cursor,Link,ETag,Last-Modified",
 	}))
 
 	// Optional request logger
@@ -75,6 +107,9 @@ func NewEngineWithConfig(data map[string]interface{}, config EngineConfig) *Engi
 
 	// Normalize input data
 	e.normalizeData(data)
+	e.versions.rebuild(e.store, e.ids)
+	e.search.rebuild(e.store, e.ids)
+	e.rebuildGraphQLSchema()
 
 	// Register dynamic routes
 	e.registerRoutes()
@@ -82,7 +117,8 @@ func NewEngineWithConfig(data map[string]interface{}, config EngineConfig) *Engi
 	return e
 }
 
-// normalizeData converts the input JSON into slices for consistent handling.
+// normalizeData converts the input JSON into slices for consistent handling
+// and gives items without an id one from their resource's strategy.
 func (e *Engine) normalizeData(data map[string]interface{}) {
 	for key, value := range data {
 		switch v := value.(type) {
@@ -90,22 +126,25 @@ func (e *Engine) normalizeData(data map[string]interface{}) {
 			items := make([]map[string]interface{}, 0, len(v))
 			for _, item := range v {
 				if m, ok := item.(map[string]interface{}); ok {
-					if _, hasID := m["id"]; !hasID {
-						m["id"] = uuid.New().String()
-					}
 					items = append(items, m)
 				}
 			}
 			e.store[key] = items
 		case map[string]interface{}:
-			if _, hasID := v["id"]; !hasID {
-				v["id"] = uuid.New().String()
-			}
 			e.store[key] = []map[string]interface{}{v}
 		default:
 			continue
 		}
 	}
+
+	// Autoincrement ids continue from the largest id in the data
+	e.ids.rebuild(e.store)
+	for resource, items := range e.store {
+		taken := e.takenIDs(resource)
+		for _, item := range items {
+			e.ids.assign(resource, item, taken)
+		}
+	}
 }
 
 // ReloadData replaces the current store with new data (for hot-reload).
@@ -117,39 +156,49 @@ func (e *Engine) ReloadData(data map[string]interface{}) {
 	e.store = make(map[string][]map[string]interface{})
 
 	// Reload with new data
-	for key, value := range data {
-		switch v := value.(type) {
-		case []interface{}:
-			items := make([]map[string]interface{}, 0, len(v))
-			for _, item := range v {
-				if m, ok := item.(map[string]interface{}); ok {
-					if _, hasID := m["id"]; !hasID {
-						m["id"] = uuid.New().String()
-					}
-					items = append(items, m)
-				}
-			}
-			e.store[key] = items
-		case map[string]interface{}:
-			if _, hasID := v["id"]; !hasID {
-				v["id"] = uuid.New().String()
-			}
-			e.store[key] = []map[string]interface{}{v}
-		}
-	}
+	e.normalizeData(data)
+
+	e.versions.rebuild(e.store, e.ids)
+	e.search.rebuild(e.store, e.ids)
+	e.rebuildGraphQLSchema()
+	e.publishReload()
 }
 
 // registerRoutes dynamically creates CRUD endpoints for each resource.
 func (e *Engine) registerRoutes() {
+	// Stubs take precedence over generated resource routes
+	if len(e.stubs) > 0 {
+		e.app.Use(e.stubMiddleware)
+	}
+
+	// Server-Sent Events change streams
+	e.registerEventRoutes()
+
 	for resource := range e.store {
 		res := resource
 
-		e.app.Get("/"+res, e.handleGetAll(res))
-		e.app.Get("/"+res+"/:id", e.handleGetByID(res))
-		e.app.Post("/"+res, e.handleCreate(res))
-		e.app.Put("/"+res+"/:id", e.handleUpdate(res))
-		e.app.Patch("/"+res+"/:id", e.handlePatch(res))
-		e.app.Delete("/"+res+"/:id", e.handleDelete(res))
+		if e.isProxied(res) {
+			e.registerProxyResource(res)
+			continue
+		}
+
+		e.app.Head("/"+res, e.handleCount(res))
+		e.app.Get("/"+res, e.withFormat(res, "list", e.withHooks(res, "list", e.handleGetAll(res))))
+		e.app.Patch("/"+res, e.handleBulkPatch(res))
+		e.app.Delete("/"+res, e.handleBulkDelete(res))
+		e.app.Post("/"+res+"/_bulk", e.handleBulkCreate(res))
+		e.app.Get("/"+res+"/_count", e.handleCount(res))
+		e.app.Get("/"+res+"/_aggregate", e.handleAggregate(res))
+		e.app.Get("/"+res+"/:id", e.withFormat(res, "get", e.withHooks(res, "get", e.handleGetByID(res))))
+		e.app.Post("/"+res, e.withFormat(res, "create", e.withHooks(res, "create", e.handleCreate(res))))
+		e.app.Put("/"+res+"/:id", e.withFormat(res, "update", e.withHooks(res, "update", e.handleUpdate(res))))
+		e.app.Patch("/"+res+"/:id", e.withFormat(res, "patch", e.withHooks(res, "patch", e.handlePatch(res))))
+		e.app.Delete("/"+res+"/:id", e.withFormat(res, "delete", e.withHooks(res, "delete", e.handleDelete(res))))
+	}
+
+	// Operations from an OpenAPI spec
+	if e.spec != nil {
+		e.registerSpecRoutes()
 	}
 
 	// Health check
@@ -161,11 +210,58 @@ func (e *Engine) registerRoutes() {
 	})
 
 	// Database endpoint - returns all data
-	e.app.Get("/db", func(c *fiber.Ctx) error {
-		e.mu.RLock()
-		defer e.mu.RUnlock()
+	e.app.Get("/db", e.handleDB)
+
+	// Transactional batch of sub-requests
+	e.app.Post("/_batch", e.handleBatch)
+
+	// OpenAPI description of the generated API
+	e.app.Get("/openapi.json", e.handleOpenAPI)
+
+	// GraphQL API over the same store, with subscriptions over WebSocket
+	e.app.Get("/graphql", e.handleGraphQLUpgrade, e.handleGraphQL)
+	e.app.Post("/graphql", e.handleGraphQL)
+
+	// Live change feed over WebSocket
+	e.registerSocketRoutes()
+
+	// Interactive API explorer
+	e.registerExplorer()
+
+	// Scenario admin endpoints
+	e.registerScenarioRoutes()
+
+	// Fallback for unmatched requests
+	e.app.Use(e.handleNotFound)
+}
+
+// handleDB returns the entire database. With ?_filter each resource keeps only the
+// items matching the expression.
+func (e *Engine) handleDB(c *fiber.Ctx) error {
+	var expr filterExpr
+	if src := c.Query("_filter"); src != "" {
+		var err error
+		if expr, err = parseFilter(src); err != nil {
+			return filterErrorResponse(c, err)
+		}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if expr == nil {
 		return c.JSON(e.store)
-	})
+	}
+	db := make(map[string][]map[string]interface{}, len(e.store))
+	for resource, items := range e.store {
+		filtered := make([]map[string]interface{}, 0)
+		for _, item := range items {
+			if expr.eval(item) {
+				filtered = append(filtered, item)
+			}
+		}
+		db[resource] = filtered
+	}
+	return c.JSON(db)
 }
 
 // listResources returns available resource names.
@@ -180,47 +276,95 @@ func (e *Engine) listResources() []string {
 	return resources
 }
 
-// handleGetAll returns a handler with query parameter support.
-// Supports: _page, _limit, _sort, _order, q (search)
-func (e *Engine) handleGetAll(resource string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		e.mu.RLock()
-		items := make([]map[string]interface{}, len(e.store[resource]))
-		copy(items, e.store[resource])
-		e.mu.RUnlock()
+// filterItems returns the items of a resource matching the filters of a request (see applyFilters).
+func (e *Engine) filterItems(c *fiber.Ctx, resource string, skip ...string) ([]map[string]interface{}, error) {
+	e.mu.RLock()
+	items := make([]map[string]interface{}, len(e.store[resource]))
+	copy(items, e.store[resource])
+	e.mu.RUnlock()
+
+	return e.applyFilters(c, resource, items, skip...)
+}
 
-		// Full-text search: ?q=keyword
-		if q := c.Query("q"); q != "" {
-			q = strings.ToLower(q)
+// searchScoresKey is the context key under which applyFilters keeps the relevance scores of q.
+const searchScoresKey = "searchScores"
+
+// applyFilters narrows items to those matching the search, field filters and _filter expression
+// of a request. Parameters starting with "_", q, q_fields and the names in skip are not field
+// filters. It fails when _filter does not parse, and does not take the store lock.
+func (e *Engine) applyFilters(c *fiber.Ctx, resource string, items []map[string]interface{}, skip ...string) ([]map[string]interface{}, error) {
+	var expr filterExpr
+	if src := c.Query("_filter"); src != "" {
+		var err error
+		if expr, err = parseFilter(src); err != nil {
+			return nil, err
+		}
+	}
+
+	// Full-text search: ?q=garcía&q_fields=name,email, ranked by relevance
+	if q := c.Query("q"); q != "" {
+		scores := e.search.search(resource, q, splitList(c.Query("q_fields")))
+		c.Locals(searchScoresKey, scores)
+		items = rankItems(items, e.ids.field(resource), scores, c.QueryBool("_score"))
+	}
+
+	// Field filters: ?field=value
+	for key, values := range c.Queries() {
+		if !isFieldFilter(key, skip) {
+			continue // Skip special params
+		}
+		if len(values) > 0 {
 			filtered := make([]map[string]interface{}, 0)
 			for _, item := range items {
-				for _, v := range item {
-					if strings.Contains(strings.ToLower(fmt.Sprintf("%v", v)), q) {
+				if v, ok := item[key]; ok {
+					if fmt.Sprintf("%v", v) == values {
 						filtered = append(filtered, item)
-						break
 					}
 				}
 			}
 			items = filtered
 		}
+	}
 
-		// Field filters: ?field=value
-		for key, values := range c.Queries() {
-			if strings.HasPrefix(key, "_") || key == "q" {
-				continue // Skip special params
-			}
-			if len(values) > 0 {
-				filtered := make([]map[string]interface{}, 0)
-				for _, item := range items {
-					if v, ok := item[key]; ok {
-						if fmt.Sprintf("%v", v) == values {
-							filtered = append(filtered, item)
-						}
-					}
-				}
-				items = filtered
+	// Expression filter: ?_filter=(role == "admin" || age > 30) && email ~ "@example.com"
+	if expr != nil {
+		filtered := make([]map[string]interface{}, 0)
+		for _, item := range items {
+			if expr.eval(item) {
+				filtered = append(filtered, item)
 			}
 		}
+		items = filtered
+	}
+	return items, nil
+}
+
+// isFieldFilter reports whether a query parameter is a ?field=value filter.
+func isFieldFilter(key string, skip []string) bool {
+	return !strings.HasPrefix(key, "_") && key != "q" && key != "q_fields" && !containsString(skip, key)
+}
+
+// handleGetAll returns a handler with query parameter support.
+// Supports: _page, _limit, _cursor, _sort, _order, _distinct, _filter, q, q_fields, _score (search)
+func (e *Engine) handleGetAll(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Conditional GET: the list validators change with any item of the resource,
+		// and are only sent once the request turned out valid
+		e.mu.RLock()
+		rv := e.versions.resources[resource]
+		items := make([]map[string]interface{}, len(e.store[resource]))
+		copy(items, e.store[resource])
+		e.mu.RUnlock()
+
+		items, err := e.applyFilters(c, resource, items)
+		if err != nil {
+			return filterErrorResponse(c, err)
+		}
+
+		// Distinct values: ?_distinct=field
+		if field := c.Query("_distinct"); field != "" {
+			return sendList(c, rv, distinctValues(items, field))
+		}
 
 		// Sort: ?_sort=field&_order=asc|desc
 		if sortField := c.Query("_sort"); sortField != "" {
@@ -240,6 +384,31 @@ func (e *Engine) handleGetAll(resource string) fiber.Handler {
 		limit, _ := strconv.Atoi(c.Query("_limit", "0"))
 
 		totalItems := len(items)
+		c.Set("X-Total-Count", strconv.Itoa(totalItems))
+
+		// Cursor pagination: ?_cursor=&_limit=10, then ?_cursor=<next>
+		if c.Request().URI().QueryArgs().Has("_cursor") {
+			scores, _ := c.Locals(searchScoresKey).(map[string]float64)
+			items, next, prev, err := cursorPage(items, e.ids.field(resource), c.Query("_sort"), c.Query("_order", "asc"), c.Query("_cursor"), limit, scores)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "invalid_cursor",
+					"message": err.Error(),
+				})
+			}
+			if limit <= 0 {
+				limit = defaultCursorLimit
+			}
+			c.Set("X-Limit", strconv.Itoa(limit))
+			if next != "" {
+				c.Set("X-Next-Cursor", next)
+			}
+			if prev != "" {
+				c.Set("X-Prev-Cursor", prev)
+			}
+			c.Set(fiber.HeaderLink, linkHeader(paginationLinks(c)))
+			return sendList(c, rv, items)
+		}
 
 		if limit > 0 {
 			start := 0
@@ -258,12 +427,12 @@ func (e *Engine) handleGetAll(resource string) fiber.Handler {
 			}
 
 			// Add pagination headers
-			c.Set("X-Total-Count", strconv.Itoa(totalItems))
 			c.Set("X-Page", strconv.Itoa(page))
 			c.Set("X-Limit", strconv.Itoa(limit))
+			c.Set(fiber.HeaderLink, linkHeader(paginationLinks(c)))
 		}
 
-		return c.JSON(items)
+		return sendList(c, rv, items)
 	}
 }
 
@@ -276,7 +445,12 @@ func (e *Engine) handleGetByID(resource string) fiber.Handler {
 		defer e.mu.RUnlock()
 
 		for _, item := range e.store[resource] {
-			if itemID, ok := item["id"]; ok && fmt.Sprintf("%v", itemID) == id {
+			if e.ids.id(resource, item) == id {
+				v := e.versions.item(resource, id)
+				setValidators(c, v.etag(), v.modified)
+				if notModified(c, v.etag(), v.modified) {
+					return c.SendStatus(fiber.StatusNotModified)
+				}
 				return c.JSON(item)
 			}
 		}
@@ -299,14 +473,15 @@ func (e *Engine) handleCreate(resource string) fiber.Handler {
 			})
 		}
 
-		if _, hasID := body["id"]; !hasID {
-			body["id"] = uuid.New().String()
-		}
-
 		e.mu.Lock()
+		e.ids.assign(resource, body, e.takenIDs(resource))
 		e.store[resource] = append(e.store[resource], body)
+		e.publish(EventCreated, resource, body)
+		v := e.versions.item(resource, e.ids.id(resource, body))
 		e.mu.Unlock()
 
+		setValidators(c, v.etag(), v.modified)
+
 		return c.Status(fiber.StatusCreated).JSON(body)
 	}
 }
@@ -328,9 +503,16 @@ func (e *Engine) handleUpdate(resource string) fiber.Handler {
 		defer e.mu.Unlock()
 
 		for i, item := range e.store[resource] {
-			if itemID, ok := item["id"]; ok && fmt.Sprintf("%v", itemID) == id {
-				body["id"] = itemID
+			if e.ids.id(resource, item) == id {
+				if etag := e.versions.item(resource, id).etag(); preconditionFailed(c, etag) {
+					return sendPreconditionFailed(c, resource, id, etag)
+				}
+				field := e.ids.field(resource)
+				body[field] = item[field]
 				e.store[resource][i] = body
+				e.publish(EventUpdated, resource, body)
+				v := e.versions.item(resource, id)
+				setValidators(c, v.etag(), v.modified)
 				return c.JSON(body)
 			}
 		}
@@ -342,32 +524,35 @@ func (e *Engine) handleUpdate(resource string) fiber.Handler {
 	}
 }
 
-// handlePatch returns a handler that partially updates an existing item.
+// handlePatch returns a handler that partially updates an existing item. The Content-Type selects
+// the patch format: plain JSON merges top-level fields, merge-patch+json deep merges (RFC 7396),
+// and json-patch+json applies operations (RFC 6902).
 func (e *Engine) handlePatch(resource string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 
-		var body map[string]interface{}
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error":   "invalid_body",
-				"message": "Request body must be valid JSON",
-			})
+		patch, perr := parseItemPatch(c.Get(fiber.HeaderContentType), c.Body())
+		if perr != nil {
+			return perr.send(c)
 		}
 
 		e.mu.Lock()
 		defer e.mu.Unlock()
 
 		for i, item := range e.store[resource] {
-			if itemID, ok := item["id"]; ok && fmt.Sprintf("%v", itemID) == id {
-				// Merge: update only provided fields
-				for k, v := range body {
-					if k != "id" {
-						item[k] = v
-					}
+			if e.ids.id(resource, item) == id {
+				if etag := e.versions.item(resource, id).etag(); preconditionFailed(c, etag) {
+					return sendPreconditionFailed(c, resource, id, etag)
 				}
-				e.store[resource][i] = item
-				return c.JSON(item)
+				patched, perr := patch.apply(item, e.ids.field(resource))
+				if perr != nil {
+					return perr.send(c)
+				}
+				e.store[resource][i] = patched
+				e.publish(EventUpdated, resource, patched)
+				v := e.versions.item(resource, id)
+				setValidators(c, v.etag(), v.modified)
+				return c.JSON(patched)
 			}
 		}
 
@@ -388,8 +573,12 @@ func (e *Engine) handleDelete(resource string) fiber.Handler {
 
 		items := e.store[resource]
 		for i, item := range items {
-			if itemID, ok := item["id"]; ok && fmt.Sprintf("%v", itemID) == id {
+			if e.ids.id(resource, item) == id {
+				if etag := e.versions.item(resource, id).etag(); preconditionFailed(c, etag) {
+					return sendPreconditionFailed(c, resource, id, etag)
+				}
 				e.store[resource] = append(items[:i], items[i+1:]...)
+				e.publish(EventDeleted, resource, item)
 				return c.Status(fiber.StatusNoContent).Send(nil)
 			}
 		}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testData decodes a JSON data file for a test engine.
+func testData(t *testing.T, src string) map[string]interface{} {
+	t.Helper()
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(src), &data); err != nil {
+		t.Fatalf("invalid test data: %v", err)
+	}
+	return data
+}
+
+// testRequest sends a request to an engine and returns the status and decoded JSON body.
+func testRequest(t *testing.T, e *Engine, method, path, contentType, body string) (int, interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := e.App().Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	var decoded interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q", method, path, raw)
+		}
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestDBExportFilter(t *testing.T) {
+	e := NewEngine(testData(t, `{"users":[{"id":1,"role":"admin"},{"id":2,"role":"guest"}],"orders":[{"id":1,"role":"admin"},{"id":2}]}`))
+
+	status, body := testRequest(t, e, "GET", `/db?_filter=role%20%3D%3D%20%22admin%22`, "", "")
+	if status != 200 {
+		t.Fatalf("status = %d, body %v", status, body)
+	}
+	for _, resource := range []string{"users", "orders"} {
+		items := body.(map[string]interface{})[resource].([]interface{})
+		if len(items) != 1 || items[0].(map[string]interface{})["role"] != "admin" {
+			t.Errorf("%s = %v, want only the admin item", resource, items)
+		}
+	}
+
+	if status, _ := testRequest(t, e, "GET", "/db?_filter=role%20%3D%3D", "", ""); status != 400 {
+		t.Errorf("malformed _filter status = %d, want 400", status)
+	}
+}
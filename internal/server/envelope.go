@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// placeholderPattern matches envelope placeholders such as {{items}}.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// Envelope wraps plain JSON responses of resource routes. Each template is any JSON value;
+// strings that are exactly a placeholder are replaced by the raw value, others by its text.
+//
+//	list:  {{items}} {{total}} {{count}} {{page}} {{limit}} {{pages}} {{links}} {{nextCursor}} {{prevCursor}}
+//	item:  {{item}}
+//	error: {{status}} {{error}} {{message}} {{details}}
+type Envelope struct {
+	List  interface{} `json:"list"`
+	Item  interface{} `json:"item"`
+	Error interface{} `json:"error"`
+}
+
+// LoadEnvelope reads an envelope definition from a JSON file.
+func LoadEnvelope(path string) (*Envelope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if envelope.List == nil && envelope.Item == nil && envelope.Error == nil {
+		return nil, fmt.Errorf("define at least one of list, item or error")
+	}
+	if envelope.List != nil && !hasPlaceholder(envelope.List, "items") {
+		return nil, fmt.Errorf("list envelope must contain {{items}}")
+	}
+	if envelope.Item != nil && !hasPlaceholder(envelope.Item, "item") {
+		return nil, fmt.Errorf("item envelope must contain {{item}}")
+	}
+	return &envelope, nil
+}
+
+// envelopeResponse wraps the plain JSON response of a resource route in the configured envelope.
+func (e *Engine) envelopeResponse(c *fiber.Ctx) error {
+	if e.envelope == nil {
+		return nil
+	}
+	status := c.Response().StatusCode()
+	body, err := parseJSONBody(c.Response().Body())
+	if err != nil || body == nil {
+		return nil // Empty or not JSON: nothing to wrap
+	}
+
+	var doc interface{}
+	switch v := body.(type) {
+	case []interface{}:
+		if e.envelope.List == nil {
+			return nil
+		}
+		total := totalCount(c, len(v))
+		vars := map[string]interface{}{
+			"items":      v,
+			"count":      len(v),
+			"total":      total,
+			"page":       1,
+			"limit":      nil,
+			"pages":      1,
+			"links":      paginationLinks(c),
+			"nextCursor": nil,
+			"prevCursor": nil,
+		}
+		if next := string(c.Response().Header.Peek("X-Next-Cursor")); next != "" {
+			vars["nextCursor"] = next
+		}
+		if prev := string(c.Response().Header.Peek("X-Prev-Cursor")); prev != "" {
+			vars["prevCursor"] = prev
+		}
+		if limit, err := strconv.Atoi(string(c.Response().Header.Peek("X-Limit"))); err == nil && limit > 0 {
+			page, _ := strconv.Atoi(string(c.Response().Header.Peek("X-Page")))
+			vars["page"] = max(page, 1)
+			vars["limit"] = limit
+			vars["pages"] = max((total+limit-1)/limit, 1)
+		}
+		doc = applyEnvelope(e.envelope.List, vars)
+	case map[string]interface{}:
+		if status >= fiber.StatusBadRequest {
+			if e.envelope.Error == nil {
+				return nil
+			}
+			doc = applyEnvelope(e.envelope.Error, map[string]interface{}{
+				"status":  status,
+				"error":   v["error"],
+				"message": v["message"],
+				"details": v["details"],
+			})
+		} else {
+			if e.envelope.Item == nil {
+				return nil
+			}
+			doc = applyEnvelope(e.envelope.Item, map[string]interface{}{"item": v})
+		}
+	default:
+		return nil
+	}
+
+	return c.Status(status).JSON(doc)
+}
+
+// applyEnvelope replaces the placeholders of a template with vars.
+func applyEnvelope(tmpl interface{}, vars map[string]interface{}) interface{} {
+	switch t := tmpl.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			out[k] = applyEnvelope(v, vars)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, v := range t {
+			out[i] = applyEnvelope(v, vars)
+		}
+		return out
+	case string:
+		// A lone placeholder keeps the type of its value
+		if m := placeholderPattern.FindStringSubmatch(t); m != nil && m[0] == strings.TrimSpace(t) {
+			return vars[m[1]]
+		}
+		return placeholderPattern.ReplaceAllStringFunc(t, func(match string) string {
+			name := placeholderPattern.FindStringSubmatch(match)[1]
+			if vars[name] == nil {
+				return ""
+			}
+			return fmt.Sprintf("%v", vars[name])
+		})
+	}
+	return tmpl
+}
+
+// hasPlaceholder reports whether a template uses a placeholder.
+func hasPlaceholder(tmpl interface{}, name string) bool {
+	switch t := tmpl.(type) {
+	case map[string]interface{}:
+		for _, v := range t {
+			if hasPlaceholder(v, name) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range t {
+			if hasPlaceholder(v, name) {
+				return true
+			}
+		}
+	case string:
+		for _, m := range placeholderPattern.FindAllStringSubmatch(t, -1) {
+			if m[1] == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// linkHeader formats pagination links as an RFC 5988 Link header.
+func linkHeader(links map[string]string) string {
+	parts := make([]string, 0, len(links))
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if href, ok := links[rel]; ok {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, href, rel))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
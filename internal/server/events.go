@@ -0,0 +1,128 @@
+package server
+
+import (
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store change event types.
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventReloaded = "reloaded" // The data file was hot-reloaded
+)
+
+// eventBuffer is the number of events a subscriber may lag behind before events are dropped.
+const eventBuffer = 256
+
+// eventLogSize is the number of past events kept for resumption.
+const eventLogSize = 1000
+
+// ChangeEvent describes a create, update or delete in the store, or a reload of the whole store.
+type ChangeEvent struct {
+	Seq      uint64                 `json:"seq"`
+	Type     string                 `json:"type"`
+	Resource string                 `json:"resource"`
+	ID       string                 `json:"id"`
+	Item     map[string]interface{} `json:"item,omitempty"`
+	Time     time.Time              `json:"time"`
+}
+
+// matches reports whether the event concerns a resource (any when empty) and id (any when empty).
+// Reload events concern every resource but no single item.
+func (ev ChangeEvent) matches(resource, id string) bool {
+	if ev.Type == EventReloaded {
+		return id == ""
+	}
+	return (resource == "" || ev.Resource == resource) && (id == "" || ev.ID == id)
+}
+
+// eventHub fans out store changes to subscribers and keeps a bounded log of recent events.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan ChangeEvent]struct{}
+	log  []ChangeEvent
+	seq  uint64
+}
+
+// newEventHub creates an empty hub.
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan ChangeEvent]struct{})}
+}
+
+// subscribe registers a new subscriber. Call unsubscribe when done.
+func (h *eventHub) subscribe() chan ChangeEvent {
+	ch := make(chan ChangeEvent, eventBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+// subscribeSince registers a subscriber and returns the logged events after seq.
+// Events published later are only delivered on the channel, so none is missed or duplicated.
+func (h *eventHub) subscribeSince(seq uint64) ([]ChangeEvent, chan ChangeEvent) {
+	ch := make(chan ChangeEvent, eventBuffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[ch] = struct{}{}
+
+	start := sort.Search(len(h.log), func(i int) bool { return h.log[i].Seq > seq })
+	backlog := make([]ChangeEvent, len(h.log)-start)
+	copy(backlog, h.log[start:])
+	return backlog, ch
+}
+
+// unsubscribe removes a subscriber.
+func (h *eventHub) unsubscribe(ch chan ChangeEvent) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+// publish numbers and logs an event, then delivers it to every subscriber without blocking;
+// slow subscribers miss events.
+func (h *eventHub) publish(ev ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	ev.Seq = h.seq
+	h.log = append(h.log, ev)
+	if len(h.log) > eventLogSize {
+		h.log = h.log[len(h.log)-eventLogSize:]
+	}
+
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// publish records a change of an item in the id counters, the item versions, the search index and the change feed.
+// It must be called with the store lock held; the item is copied.
+func (e *Engine) publish(eventType, resource string, item map[string]interface{}) {
+	id := e.ids.id(resource, item)
+	if eventType != EventDeleted {
+		e.ids.observe(resource, item)
+	}
+	e.versions.update(resource, id)
+	e.search.update(eventType, resource, id, item)
+	e.events.publish(ChangeEvent{
+		Type:     eventType,
+		Resource: resource,
+		ID:       id,
+		Item:     maps.Clone(item),
+		Time:     time.Now().UTC(),
+	})
+}
+
+// publishReload records that the whole store was replaced.
+func (e *Engine) publishReload() {
+	e.events.publish(ChangeEvent{Type: EventReloaded, Time: time.Now().UTC()})
+}
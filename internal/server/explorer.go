@@ -0,0 +1,30 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+)
+
+// explorerAssets holds the API explorer page, served without any CDN.
+//
+//go:embed explorer
+var explorerAssets embed.FS
+
+// ExplorerPath is the reserved path of the API explorer.
+const ExplorerPath = "/_explorer"
+
+// registerExplorer serves the embedded API explorer, built on /openapi.json.
+func (e *Engine) registerExplorer() {
+	assets, err := fs.Sub(explorerAssets, "explorer")
+	if err != nil {
+		panic(err) // The directory is embedded at build time
+	}
+
+	e.app.Use(ExplorerPath, filesystem.New(filesystem.Config{
+		Root:  http.FS(assets),
+		Index: "index.html",
+	}))
+}
@@ -0,0 +1,233 @@
+// Insta-Mock API explorer: renders /openapi.json and fires requests at the running mock.
+(function () {
+  "use strict";
+
+  const $ = (id) => document.getElementById(id);
+  const methods = ["get", "post", "put", "patch", "delete"];
+  let spec = null;
+  let current = null;
+
+  // resolve follows local $ref pointers such as #/components/schemas/User.
+  function resolve(node, depth) {
+    depth = depth || 0;
+    if (!node || typeof node !== "object" || depth > 8) return node;
+    if (node.$ref) {
+      const target = node.$ref
+        .replace(/^#\//, "")
+        .split("/")
+        .reduce((acc, key) => (acc ? acc[key] : undefined), spec);
+      return resolve(target, depth + 1);
+    }
+    if (Array.isArray(node)) return node.map((n) => resolve(n, depth + 1));
+    const out = {};
+    for (const [k, v] of Object.entries(node)) out[k] = resolve(v, depth + 1);
+    return out;
+  }
+
+  // sample builds an example value from a schema, used to prefill request bodies.
+  function sample(schema, depth) {
+    depth = depth || 0;
+    schema = resolve(schema) || {};
+    if (depth > 6) return null;
+    if (schema.example !== undefined) return schema.example;
+    if (schema.enum) return schema.enum[0];
+    if (schema.allOf) return Object.assign({}, ...schema.allOf.map((s) => sample(s, depth + 1)));
+    if (schema.oneOf || schema.anyOf) return sample((schema.oneOf || schema.anyOf)[0], depth + 1);
+    const type = Array.isArray(schema.type) ? schema.type.find((t) => t !== "null") : schema.type;
+    switch (type) {
+      case "object": {
+        const obj = {};
+        for (const [k, v] of Object.entries(schema.properties || {})) {
+          if (k !== "id") obj[k] = sample(v, depth + 1);
+        }
+        return obj;
+      }
+      case "array":
+        return [sample(schema.items || {}, depth + 1)];
+      case "integer":
+      case "number":
+        return schema.minimum !== undefined ? schema.minimum : 1;
+      case "boolean":
+        return true;
+      case "string":
+        return { email: "user@example.com", uuid: "00000000-0000-0000-0000-000000000000", date: "2024-01-01", "date-time": "2024-01-01T00:00:00Z", uri: "https://example.com" }[schema.format] || "string";
+      default:
+        return null;
+    }
+  }
+
+  function renderNav() {
+    const filter = $("filter").value.toLowerCase();
+    const groups = {};
+    for (const [path, item] of Object.entries(spec.paths || {})) {
+      for (const method of methods) {
+        const op = item[method];
+        if (!op) continue;
+        if (filter && !(path + " " + method).toLowerCase().includes(filter)) continue;
+        const tag = (op.tags && op.tags[0]) || path.split("/")[1] || "/";
+        (groups[tag] = groups[tag] || []).push({ path, method, op, item });
+      }
+    }
+
+    const nav = $("endpoints");
+    nav.innerHTML = "";
+    const tags = Object.keys(groups).sort();
+    if (tags.length === 0) {
+      nav.innerHTML = '<p class="muted" style="padding: 0 1rem">No endpoints.</p>';
+      return;
+    }
+    for (const tag of tags) {
+      const h = document.createElement("h4");
+      h.textContent = tag;
+      nav.appendChild(h);
+      for (const entry of groups[tag]) {
+        const btn = document.createElement("button");
+        btn.innerHTML = `<span class="method ${entry.method}">${entry.method}</span><code></code>`;
+        btn.querySelector("code").textContent = entry.path;
+        btn.onclick = () => {
+          nav.querySelectorAll("button").forEach((b) => b.classList.remove("active"));
+          btn.classList.add("active");
+          select(entry);
+        };
+        nav.appendChild(btn);
+      }
+    }
+  }
+
+  function select(entry) {
+    current = entry;
+    $("empty").hidden = true;
+    $("operation").hidden = false;
+    $("response").hidden = true;
+
+    const methodEl = $("op-method");
+    methodEl.textContent = entry.method;
+    methodEl.className = "method " + entry.method;
+    $("op-path").textContent = entry.path;
+    $("op-summary").textContent = entry.op.summary || entry.op.description || "";
+
+    const params = [...(entry.item.parameters || []), ...(entry.op.parameters || [])].map((p) => resolve(p));
+
+    // Path parameters get their own inputs
+    const pathParams = $("path-params");
+    pathParams.innerHTML = "";
+    for (const p of params.filter((p) => p.in === "path")) {
+      const label = document.createElement("label");
+      label.textContent = p.name;
+      const input = document.createElement("input");
+      input.dataset.param = p.name;
+      input.required = true;
+      input.placeholder = p.name;
+      label.appendChild(input);
+      pathParams.appendChild(label);
+    }
+
+    // Query parameters are listed as clickable hints
+    $("query").value = "";
+    const help = $("query-help");
+    help.innerHTML = "";
+    for (const p of params.filter((p) => p.in === "query")) {
+      const chip = document.createElement("span");
+      chip.textContent = p.name;
+      chip.title = p.description || "";
+      chip.onclick = () => {
+        const q = $("query");
+        q.value = (q.value ? q.value + "&" : "") + p.name + "=";
+        q.focus();
+      };
+      help.appendChild(chip);
+    }
+
+    // Request body, prefilled from the schema
+    const content = entry.op.requestBody && resolve(entry.op.requestBody).content;
+    const media = content && (content["application/json"] || Object.values(content)[0]);
+    $("body-label").hidden = !media;
+    $("body").value = media ? JSON.stringify(media.example || sample(media.schema), null, 2) : "";
+
+    // Schema of the success response (or the request body)
+    const responses = entry.op.responses || {};
+    const code = Object.keys(responses).sort().find((c) => c.startsWith("2"));
+    const res = code && resolve(responses[code]);
+    const resMedia = res && res.content && (res.content["application/json"] || Object.values(res.content)[0]);
+    const schema = (resMedia && resMedia.schema) || (media && media.schema);
+    $("schema").textContent = schema ? JSON.stringify(resolve(schema), null, 2) : "No schema.";
+  }
+
+  // readStream collects an event stream for a few seconds, since it never ends on its own.
+  async function readStream(res, ms) {
+    const reader = res.body.getReader();
+    const decoder = new TextDecoder();
+    let text = "";
+    const timer = setTimeout(() => reader.cancel(), ms);
+    try {
+      for (;;) {
+        const { done, value } = await reader.read();
+        if (done) break;
+        text += decoder.decode(value, { stream: true });
+      }
+    } catch (_) {
+      // Cancelled
+    }
+    clearTimeout(timer);
+    return text;
+  }
+
+  async function send(event) {
+    event.preventDefault();
+    if (!current) return;
+
+    let path = current.path;
+    document.querySelectorAll("#path-params input").forEach((input) => {
+      path = path.replace("{" + input.dataset.param + "}", encodeURIComponent(input.value));
+    });
+    const query = $("query").value.trim();
+    const url = path + (query ? "?" + query.replace(/^\?/, "") : "");
+
+    const options = { method: current.method.toUpperCase(), headers: {} };
+    if (!$("body-label").hidden && $("body").value.trim()) {
+      options.headers["Content-Type"] = "application/json";
+      options.body = $("body").value;
+    }
+
+    const started = performance.now();
+    let res;
+    try {
+      res = await fetch(url, options);
+    } catch (err) {
+      $("response").hidden = false;
+      $("res-status").textContent = "network error";
+      $("res-status").className = "status error";
+      $("res-body").textContent = String(err);
+      return;
+    }
+    const streaming = (res.headers.get("content-type") || "").startsWith("text/event-stream");
+    const text = streaming ? await readStream(res, 5000) : await res.text();
+    const elapsed = Math.round(performance.now() - started);
+
+    $("response").hidden = false;
+    $("res-status").textContent = res.status + " " + res.statusText;
+    $("res-status").className = "status " + (res.ok ? "ok" : "error");
+    $("res-time").textContent = elapsed + " ms · " + options.method + " " + url;
+    $("res-headers").textContent = [...res.headers.entries()].map(([k, v]) => k + ": " + v).join("\n");
+    try {
+      $("res-body").textContent = JSON.stringify(JSON.parse(text), null, 2);
+    } catch (_) {
+      $("res-body").textContent = text || "(empty body)";
+    }
+  }
+
+  $("filter").addEventListener("input", renderNav);
+  $("request").addEventListener("submit", send);
+
+  fetch("/openapi.json")
+    .then((res) => res.json())
+    .then((doc) => {
+      spec = doc;
+      document.title = (doc.info && doc.info.title ? doc.info.title + " · " : "") + "Insta-Mock Explorer";
+      renderNav();
+    })
+    .catch((err) => {
+      $("endpoints").innerHTML = '<p class="muted" style="padding: 0 1rem"></p>';
+      $("endpoints").firstChild.textContent = "Could not load /openapi.json: " + err;
+    });
+})();
@@ -0,0 +1,440 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Limits that keep _filter parsing cheap on hostile input.
+const (
+	maxFilterLength = 2000
+	maxFilterDepth  = 32
+)
+
+// filterError is a _filter syntax error at a byte offset of the expression.
+type filterError struct {
+	Pos int
+	Msg string
+}
+
+func (err *filterError) Error() string {
+	return fmt.Sprintf("%s at position %d", err.Msg, err.Pos)
+}
+
+// filterExpr is a parsed _filter expression.
+//
+//	expr       := or
+//	or         := and (("||" | "or") and)*
+//	and        := not (("&&" | "and") not)*
+//	not        := ("!" | "not") not | "(" expr ")" | comparison
+//	comparison := field [op value]          (a lone field tests that it is truthy)
+//	op         := == != > >= < <= ~ !~ in
+//	value      := "string" | 'string' | number | true | false | null | [value, ...]
+//
+// ~ is a case-insensitive "contains"; fields are names or dot paths (address.city).
+type filterExpr interface {
+	eval(item map[string]interface{}) bool
+}
+
+type filterAnd struct{ left, right filterExpr }
+type filterOr struct{ left, right filterExpr }
+type filterNot struct{ expr filterExpr }
+
+// filterCompare compares a field with a literal; op is empty for a truthiness test.
+type filterCompare struct {
+	field []string
+	op    string
+	value interface{}
+}
+
+func (f filterAnd) eval(item map[string]interface{}) bool {
+	return f.left.eval(item) && f.right.eval(item)
+}
+
+func (f filterOr) eval(item map[string]interface{}) bool {
+	return f.left.eval(item) || f.right.eval(item)
+}
+
+func (f filterNot) eval(item map[string]interface{}) bool {
+	return !f.expr.eval(item)
+}
+
+func (f filterCompare) eval(item map[string]interface{}) bool {
+	var actual interface{} = item
+	for _, part := range f.field {
+		m, ok := actual.(map[string]interface{})
+		if !ok {
+			actual = nil
+			break
+		}
+		actual = m[part]
+	}
+
+	switch f.op {
+	case "":
+		return isTruthy(actual)
+	case "==":
+		return filterEqual(actual, f.value)
+	case "!=":
+		return !filterEqual(actual, f.value)
+	case "~", "!~":
+		contains := actual != nil && strings.Contains(
+			strings.ToLower(fmt.Sprintf("%v", actual)),
+			strings.ToLower(fmt.Sprintf("%v", f.value)))
+		return contains == (f.op == "~")
+	case "in":
+		for _, v := range f.value.([]interface{}) {
+			if filterEqual(actual, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Ordering comparisons never match missing or null values
+	if actual == nil || f.value == nil {
+		return false
+	}
+	d := compareValues(actual, f.value)
+	switch f.op {
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	}
+	return d <= 0
+}
+
+// filterEqual compares like the plain ?field=value filters: numbers numerically, anything else by its text.
+func filterEqual(actual, want interface{}) bool {
+	if actual == nil || want == nil {
+		return actual == nil && want == nil
+	}
+	return compareValues(actual, want) == 0
+}
+
+// isTruthy reports whether a value is set and not false, zero or empty.
+func isTruthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case []interface{}:
+		return len(t) > 0
+	}
+	if n, ok := toNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// filterToken is a lexical token of a _filter expression.
+type filterToken struct {
+	kind  string // "ident", "string", "number", "op", "punct" or "eof"
+	text  string
+	value interface{}
+	pos   int
+}
+
+// filterParser is a recursive descent parser over the tokens of an expression.
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	depth  int
+}
+
+// parseFilter parses a _filter expression.
+func parseFilter(src string) (filterExpr, error) {
+	if len(src) > maxFilterLength {
+		return nil, &filterError{Pos: maxFilterLength, Msg: fmt.Sprintf("expression is longer than %d characters", maxFilterLength)}
+	}
+	tokens, err := lexFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != "eof" {
+		return nil, &filterError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected '%s'", tok.text)}
+	}
+	return expr, nil
+}
+
+func (p *filterParser) peek() filterToken { return p.tokens[p.pos] }
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != "eof" {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of texts (operators or keywords).
+func (p *filterParser) accept(texts ...string) bool {
+	tok := p.peek()
+	if tok.kind != "op" && tok.kind != "punct" && tok.kind != "ident" {
+		return false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterExpr, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxFilterDepth {
+		return nil, &filterError{Pos: p.peek().pos, Msg: fmt.Sprintf("expression is nested deeper than %d levels", maxFilterDepth)}
+	}
+
+	if p.accept("!", "not") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{expr}, nil
+	}
+	if p.accept("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.expected("')'")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterExpr, error) {
+	tok := p.peek()
+	if tok.kind != "ident" || isFilterKeyword(tok.text) {
+		return nil, p.expected("a field name")
+	}
+	p.next()
+	cmp := filterCompare{field: strings.Split(tok.text, ".")}
+
+	op := p.peek()
+	if op.kind != "op" && !(op.kind == "ident" && op.text == "in") {
+		return cmp, nil // Truthiness test
+	}
+	if op.text == "!" || op.text == "&&" || op.text == "||" {
+		return cmp, nil
+	}
+	p.next()
+	cmp.op = op.text
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, isList := value.([]interface{}); isList != (cmp.op == "in") {
+		if isList {
+			return nil, &filterError{Pos: op.pos, Msg: fmt.Sprintf("operator '%s' does not take a list", op.text)}
+		}
+		return nil, &filterError{Pos: op.pos, Msg: "operator 'in' takes a list such as [1, 2]"}
+	}
+	cmp.value = value
+	return cmp, nil
+}
+
+func (p *filterParser) parseValue() (interface{}, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == "string" || tok.kind == "number":
+		p.next()
+		return tok.value, nil
+	case tok.kind == "ident" && (tok.text == "true" || tok.text == "false"):
+		p.next()
+		return tok.text == "true", nil
+	case tok.kind == "ident" && tok.text == "null":
+		p.next()
+		return nil, nil
+	case p.accept("["):
+		list := make([]interface{}, 0)
+		if p.accept("]") {
+			return list, nil
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if _, nested := v.([]interface{}); nested {
+				return nil, &filterError{Pos: tok.pos, Msg: "lists cannot be nested"}
+			}
+			list = append(list, v)
+			if p.accept("]") {
+				return list, nil
+			}
+			if !p.accept(",") {
+				return nil, p.expected("',' or ']'")
+			}
+		}
+	}
+	return nil, p.expected("a value (string, number, true, false, null or list)")
+}
+
+// expected returns a syntax error for the next token.
+func (p *filterParser) expected(what string) error {
+	tok := p.peek()
+	if tok.kind == "eof" {
+		return &filterError{Pos: tok.pos, Msg: fmt.Sprintf("expected %s, got end of expression", what)}
+	}
+	return &filterError{Pos: tok.pos, Msg: fmt.Sprintf("expected %s, got '%s'", what, tok.text)}
+}
+
+// isFilterKeyword reports whether an identifier is reserved by the language.
+func isFilterKeyword(s string) bool {
+	switch s {
+	case "and", "or", "not", "in", "true", "false", "null":
+		return true
+	}
+	return false
+}
+
+// lexFilter splits an expression into tokens.
+func lexFilter(src string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(src) {
+					return nil, &filterError{Pos: start, Msg: "unterminated string"}
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					sb.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if rune(src[i]) == r {
+					i++
+					break
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: "string", text: src[start:i], value: sb.String(), pos: start})
+		case isDigit(r) || (r == '-' && i+1 < len(src) && isDigit(rune(src[i+1]))):
+			start := i
+			i++
+			for i < len(src) && (isDigit(rune(src[i])) || src[i] == '.' || src[i] == 'e' || src[i] == 'E') {
+				i++
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, &filterError{Pos: start, Msg: fmt.Sprintf("invalid number '%s'", src[start:i])}
+			}
+			tokens = append(tokens, filterToken{kind: "number", text: src[start:i], value: n, pos: start})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(src) {
+				c, n := utf8.DecodeRuneInString(src[i:])
+				if c != '_' && c != '.' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+					break
+				}
+				i += n
+			}
+			text := src[start:i]
+			if strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+				return nil, &filterError{Pos: start, Msg: fmt.Sprintf("invalid field path '%s'", text)}
+			}
+			tokens = append(tokens, filterToken{kind: "ident", text: text, pos: start})
+		case strings.ContainsRune("()[],", r):
+			tokens = append(tokens, filterToken{kind: "punct", text: string(r), pos: i})
+			i++
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", ">=", "<=", "&&", "||", "!~", ">", "<", "~", "!"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				if src[i] == '=' {
+					return nil, &filterError{Pos: i, Msg: "unexpected '=', use '==' to compare"}
+				}
+				return nil, &filterError{Pos: i, Msg: fmt.Sprintf("unexpected character '%c'", r)}
+			}
+			tokens = append(tokens, filterToken{kind: "op", text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, filterToken{kind: "eof", pos: len(src)}), nil
+}
+
+// isDigit reports whether r is an ASCII digit.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// filterErrorResponse answers a request whose _filter does not parse.
+func filterErrorResponse(c *fiber.Ctx, err error) error {
+	details := fiber.Map{}
+	if fe, ok := err.(*filterError); ok {
+		details["position"] = fe.Pos
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "invalid_filter",
+		"message": "Invalid _filter: " + err.Error(),
+		"details": details,
+	})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFilterErrorPositions(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{`(role == "admin"`, 16, "expected ')'"},
+		{`role == "admin`, 8, "unterminated string"},
+		{`age = 3`, 4, "use '=='"},
+		{`age > 3 #`, 8, "unexpected character '#'"},
+		{`age > 3 age`, 8, "unexpected 'age'"},
+		{`role in "admin"`, 5, "takes a list"},
+		{`role in [[1]]`, 8, "cannot be nested"}, // At the list holding the nested one
+		{`age > 1.2.3`, 6, "invalid number"},
+		{`age >`, 5, "got end of expression"},
+		{strings.Repeat("(", 40) + "a" + strings.Repeat(")", 40), maxFilterDepth, "nested deeper"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := parseFilter(tt.src)
+			fe, ok := err.(*filterError)
+			if !ok {
+				t.Fatalf("parseFilter(%q) error = %v, want a *filterError", tt.src, err)
+			}
+			if fe.Pos != tt.pos || !strings.Contains(fe.Msg, tt.msg) {
+				t.Errorf("parseFilter(%q) = %q at %d, want %q at %d", tt.src, fe.Msg, fe.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	item := map[string]interface{}{"role": "admin", "age": float64(31), "address": map[string]interface{}{"city": "Lima"}}
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`role == "admin" && age > 30`, true},
+		{`(role == 'guest' || age >= 40) and address.city == 'Lima'`, false},
+		{`not role in ["guest", "owner"]`, true},
+		{`missing == null`, true},
+		{`address.city ~ "LI"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := parseFilter(tt.src)
+			if err != nil {
+				t.Fatalf("parseFilter(%q): %v", tt.src, err)
+			}
+			if got := expr.eval(item); got != tt.want {
+				t.Errorf("%q on %v = %v, want %v", tt.src, item, got, tt.want)
+			}
+		})
+	}
+}
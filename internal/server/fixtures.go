@@ -0,0 +1,304 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// redactedValue replaces sensitive header and field values in captured fixtures.
+const redactedValue = "[REDACTED]"
+
+// Exchange is a captured HTTP request/response pair.
+type Exchange struct {
+	Method          string
+	Path            string
+	Query           map[string]string
+	RequestBody     []byte
+	Status          int
+	ResponseHeaders map[string]string
+	ResponseBody    []byte
+}
+
+// RedactConfig lists headers and JSON fields whose values must not be written to disk.
+type RedactConfig struct {
+	Headers []string
+	Fields  []string
+}
+
+// Fixtures turns captured exchanges into resource collections and stubs.
+// REST-shaped JSON exchanges (GET /users, GET /users/1, POST /users) become
+// collection items; everything else becomes a static stub.
+type Fixtures struct {
+	redact    RedactConfig
+	resources map[string][]map[string]interface{}
+	stubs     []Stub
+	stubIndex map[string]int
+}
+
+// skippedResponseHeaders are transport headers never copied into stubs.
+var skippedResponseHeaders = map[string]bool{
+	"content-length":    true,
+	"content-encoding":  true,
+	"connection":        true,
+	"date":              true,
+	"transfer-encoding": true,
+	"keep-alive":        true,
+	"server":            true,
+}
+
+// NewFixtures creates an empty fixture collector.
+func NewFixtures(redact RedactConfig) *Fixtures {
+	return &Fixtures{
+		redact:    redact,
+		resources: make(map[string][]map[string]interface{}),
+		stubIndex: make(map[string]int),
+	}
+}
+
+// Add records an exchange as collection items or as a stub.
+func (f *Fixtures) Add(ex Exchange) {
+	body, bodyErr := parseJSONBody(ex.ResponseBody)
+	if bodyErr == nil {
+		body = f.redactJSON(body)
+		if f.addToResource(ex, body) {
+			return
+		}
+	}
+	f.addStub(ex, body, bodyErr == nil)
+}
+
+// addToResource stores REST-shaped responses in their collection.
+func (f *Fixtures) addToResource(ex Exchange, body interface{}) bool {
+	if ex.Status < 200 || ex.Status >= 300 || len(ex.Query) > 0 {
+		return false
+	}
+	segments := strings.Split(strings.Trim(ex.Path, "/"), "/")
+	if segments[0] == "" || strings.HasPrefix(segments[0], "_") {
+		return false
+	}
+	resource := segments[0]
+
+	switch {
+	case len(segments) == 1 && ex.Method == "GET":
+		arr, ok := body.([]interface{})
+		if !ok {
+			return false
+		}
+		items := make([]map[string]interface{}, 0, len(arr))
+		for _, v := range arr {
+			item, ok := v.(map[string]interface{})
+			if !ok || item["id"] == nil {
+				return false
+			}
+			items = append(items, item)
+		}
+		for _, item := range items {
+			f.upsert(resource, item)
+		}
+		f.ensureResource(resource)
+		return true
+
+	case len(segments) == 1 && ex.Method == "POST", len(segments) == 2 && ex.Method == "GET":
+		item, ok := body.(map[string]interface{})
+		if !ok || item["id"] == nil {
+			return false
+		}
+		if len(segments) == 2 && fmt.Sprintf("%v", item["id"]) != segments[1] {
+			return false
+		}
+		f.upsert(resource, item)
+		return true
+	}
+
+	return false
+}
+
+// ensureResource makes sure an (possibly empty) collection exists.
+func (f *Fixtures) ensureResource(resource string) {
+	if _, ok := f.resources[resource]; !ok {
+		f.resources[resource] = []map[string]interface{}{}
+	}
+}
+
+// upsert adds an item to a collection, replacing an existing item with the same id.
+func (f *Fixtures) upsert(resource string, item map[string]interface{}) {
+	id := fmt.Sprintf("%v", item["id"])
+	for i, existing := range f.resources[resource] {
+		if fmt.Sprintf("%v", existing["id"]) == id {
+			f.resources[resource][i] = item
+			return
+		}
+	}
+	f.resources[resource] = append(f.resources[resource], item)
+}
+
+// addStub records a non-CRUD exchange as a static stub. Later exchanges for the
+// same request replace earlier ones.
+func (f *Fixtures) addStub(ex Exchange, body interface{}, isJSON bool) {
+	req := StubRequest{Method: ex.Method, Path: ex.Path}
+
+	if len(ex.Query) > 0 {
+		req.Query = make(map[string]Matcher, len(ex.Query))
+		for k, v := range ex.Query {
+			value := v
+			req.Query[k] = Matcher{EqualTo: &value}
+		}
+	}
+
+	if reqBody, err := parseJSONBody(ex.RequestBody); err == nil {
+		if obj, ok := reqBody.(map[string]interface{}); ok {
+			partial := f.withoutRedactedFields(obj)
+			if len(partial) > 0 {
+				req.Body = []BodyMatcher{{Partial: partial}}
+			}
+		}
+	}
+
+	resp := StubResponse{Status: ex.Status}
+	for k, v := range ex.ResponseHeaders {
+		if skippedResponseHeaders[strings.ToLower(k)] {
+			continue
+		}
+		if resp.Headers == nil {
+			resp.Headers = make(map[string]string)
+		}
+		if f.isRedactedHeader(k) {
+			v = redactedValue
+		}
+		resp.Headers[k] = v
+	}
+	if isJSON {
+		resp.Body = body
+	} else {
+		resp.Text = string(ex.ResponseBody)
+	}
+
+	key := stubKey(req)
+	if i, ok := f.stubIndex[key]; ok {
+		f.stubs[i].Response = resp
+		return
+	}
+	f.stubIndex[key] = len(f.stubs)
+	f.stubs = append(f.stubs, Stub{
+		ID:       fmt.Sprintf("%s %s", ex.Method, ex.Path),
+		Request:  req,
+		Response: resp,
+	})
+}
+
+// stubKey identifies a stub request for de-duplication.
+func stubKey(req StubRequest) string {
+	data, _ := json.Marshal(req)
+	return string(data)
+}
+
+// isRedactedHeader reports whether a header must be redacted.
+func (f *Fixtures) isRedactedHeader(name string) bool {
+	for _, h := range f.redact.Headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRedactedField reports whether a JSON field must be redacted.
+func (f *Fixtures) isRedactedField(name string) bool {
+	for _, field := range f.redact.Fields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactJSON replaces the values of redacted fields anywhere in a JSON value.
+func (f *Fixtures) redactJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if f.isRedactedField(k) {
+				val[k] = redactedValue
+			} else {
+				val[k] = f.redactJSON(item)
+			}
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = f.redactJSON(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// withoutRedactedFields drops redacted fields so stub matchers never contain secrets.
+func (f *Fixtures) withoutRedactedFields(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if !f.isRedactedField(k) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Data returns the captured collections in the data file format.
+func (f *Fixtures) Data() map[string]interface{} {
+	data := make(map[string]interface{}, len(f.resources))
+	for name, items := range f.resources {
+		arr := make([]interface{}, len(items))
+		for i, item := range items {
+			arr[i] = item
+		}
+		data[name] = arr
+	}
+	return data
+}
+
+// Stubs returns the captured stubs ordered by path.
+func (f *Fixtures) Stubs() []Stub {
+	stubs := make([]Stub, len(f.stubs))
+	copy(stubs, f.stubs)
+	sort.SliceStable(stubs, func(i, j int) bool {
+		return stubs[i].Request.Path < stubs[j].Request.Path
+	})
+	return stubs
+}
+
+// Summary returns the number of collected resources, items and stubs.
+func (f *Fixtures) Summary() (resources, items, stubs int) {
+	for _, v := range f.resources {
+		resources++
+		items += len(v)
+	}
+	return resources, items, len(f.stubs)
+}
+
+// Save writes the collections to dataPath and, when there are any, the stubs to stubsPath.
+func (f *Fixtures) Save(dataPath, stubsPath string) error {
+	if err := writeJSONFile(dataPath, f.Data()); err != nil {
+		return err
+	}
+	if len(f.stubs) == 0 || stubsPath == "" {
+		return nil
+	}
+	return writeJSONFile(stubsPath, stubFile{Stubs: f.Stubs()})
+}
+
+// writeJSONFile writes an indented JSON document.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,621 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Response formats of resource routes.
+const (
+	FormatJSON    = "json"    // Plain JSON arrays and objects
+	FormatJSONAPI = "jsonapi" // JSON:API documents (https://jsonapi.org)
+	FormatHAL     = "hal"     // HAL documents (application/hal+json)
+)
+
+// Formats lists the accepted values of EngineConfig.Format.
+var Formats = []string{FormatJSON, FormatJSONAPI, FormatHAL}
+
+// Media types of the hypermedia formats.
+const (
+	MIMEJSONAPI = "application/vnd.api+json"
+	MIMEHAL     = "application/hal+json"
+)
+
+// negotiateFormat picks the response format from the Accept header, falling back to the configured format.
+func (e *Engine) negotiateFormat(c *fiber.Ctx) string {
+	accept := c.Get(fiber.HeaderAccept)
+	switch {
+	case strings.Contains(accept, MIMEJSONAPI):
+		return FormatJSONAPI
+	case strings.Contains(accept, MIMEHAL):
+		return FormatHAL
+	case e.format != "":
+		return e.format
+	}
+	return FormatJSON
+}
+
+// requestFormat picks the format of a request body from its Content-Type, falling back to the configured format.
+func (e *Engine) requestFormat(c *fiber.Ctx) string {
+	contentType := c.Get(fiber.HeaderContentType)
+	switch {
+	case strings.HasPrefix(contentType, MIMEJSONAPI):
+		return FormatJSONAPI
+	case strings.HasPrefix(contentType, MIMEHAL):
+		return FormatHAL
+	case strings.HasPrefix(contentType, MIMEMergePatch), strings.HasPrefix(contentType, MIMEJSONPatch):
+		return FormatJSON // Patch documents are not resources
+	case e.format != "":
+		return e.format
+	}
+	return FormatJSON
+}
+
+// responseShape holds the per-request options that change the shape of returned items.
+type responseShape struct {
+	include []string            // Relations to embed (?_embed=) or include (JSON:API ?include=)
+	project *projection         // ?_fields= and ?_exclude=
+	sparse  map[string][]string // JSON:API sparse fieldsets: ?fields[users]=name,email
+}
+
+// etagVariant names the representation that a format and shape give items, for their ETags:
+// the format, and a hash of the embedded relations and projected fields. It is empty for plain JSON.
+func (s responseShape) etagVariant(c *fiber.Ctx, format string) string {
+	var key []string
+	if len(s.include) > 0 {
+		key = append(key, "include="+strings.Join(s.include, ","))
+	}
+	if s.project != nil {
+		key = append(key, "fields="+c.Query("_fields"), "exclude="+c.Query("_exclude"))
+	}
+	for _, resource := range slices.Sorted(maps.Keys(s.sparse)) {
+		key = append(key, "fields["+resource+"]="+strings.Join(s.sparse[resource], ","))
+	}
+	if len(key) == 0 {
+		if format == FormatJSON {
+			return ""
+		}
+		return format
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(key, "&")))
+	return fmt.Sprintf("%s.%08x", format, h.Sum32())
+}
+
+// withFormat converts request bodies from JSON:API or HAL to plain JSON before the handler runs,
+// and afterwards shapes its plain JSON response: embedded relations and projections, then
+// conversion to the negotiated format or the envelope.
+func (e *Engine) withFormat(resource, action string, handler fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := e.negotiateFormat(c)
+		input := e.requestFormat(c)
+		args := c.Request().URI().QueryArgs()
+		shaped := args.Has("_fields") || args.Has("_exclude") || args.Has("_embed")
+		if format == FormatJSON && input == FormatJSON && e.envelope == nil && !shaped {
+			return handler(c)
+		}
+		if action == "list" && args.Has("_distinct") {
+			return handler(c) // Distinct values are not resources
+		}
+
+		relations := e.relationsOf(resource)
+
+		// Request body
+		if input != FormatJSON && len(c.Body()) > 0 {
+			body, status, err := decodeFormatBody(input, resource, e.ids.field(resource), relations, c.Body(), c.Get(fiber.HeaderContentType))
+			if err != nil {
+				code := "invalid_body"
+				if status == fiber.StatusConflict {
+					code = "type_mismatch"
+				}
+				return formatError(c, format, status, code, err.Error())
+			}
+			if body != nil {
+				data, _ := json.Marshal(body)
+				c.Request().SetBody(data)
+				c.Request().Header.SetContentType(fiber.MIMEApplicationJSON)
+			}
+		}
+
+		// Query parameters
+		var shape responseShape
+		if format == FormatJSONAPI {
+			shape.include = splitList(utils.CopyString(c.Query("include")))
+			args.Del("include")
+			shape.sparse = jsonAPIFieldsets(c)
+			if action == "list" {
+				translateJSONAPIQuery(c)
+			}
+		} else {
+			shape.include = splitList(utils.CopyString(c.Query("_embed")))
+			shape.project = parseProjection(c)
+		}
+		for _, name := range shape.include {
+			if findRelation(relations, name) == nil {
+				return formatError(c, format, fiber.StatusBadRequest, "invalid_include",
+					fmt.Sprintf("%s has no relation '%s'", resource, name))
+			}
+		}
+
+		if action != "list" && action != "get" {
+			shape.project, shape.sparse = nil, nil // Projections only apply to reads
+		}
+		c.Locals(etagVariantKey, shape.etagVariant(c, format))
+
+		if err := handler(c); err != nil {
+			return err
+		}
+		if format == FormatJSON {
+			if err := e.shapeResponse(c, relations, shape); err != nil {
+				return err
+			}
+			return e.envelopeResponse(c)
+		}
+		if format == FormatJSONAPI && len(shape.include) > 0 {
+			args.Set("include", strings.Join(shape.include, ",")) // Kept in pagination links
+		}
+		return e.formatResponse(c, format, resource, relations, shape)
+	}
+}
+
+// shapeResponse embeds relations into, and projects, the items of a plain JSON response.
+func (e *Engine) shapeResponse(c *fiber.Ctx, relations []relation, shape responseShape) error {
+	if len(shape.include) == 0 && shape.project == nil {
+		return nil
+	}
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusBadRequest {
+		return nil
+	}
+	body, err := parseJSONBody(c.Response().Body())
+	if err != nil || body == nil {
+		return nil
+	}
+
+	shapeItem := func(item map[string]interface{}) map[string]interface{} {
+		return shape.project.apply(e.embedRelations(item, relations, shape.include))
+	}
+	switch v := body.(type) {
+	case map[string]interface{}:
+		return c.Status(status).JSON(shapeItem(v))
+	case []interface{}:
+		for i, raw := range v {
+			if item, ok := raw.(map[string]interface{}); ok {
+				v[i] = shapeItem(item)
+			}
+		}
+		return c.Status(status).JSON(v)
+	}
+	return nil
+}
+
+// formatResponse rewrites the plain JSON response of a resource route.
+func (e *Engine) formatResponse(c *fiber.Ctx, format, resource string, relations []relation, shape responseShape) error {
+	status := c.Response().StatusCode()
+	body, err := parseJSONBody(c.Response().Body())
+	if err != nil || body == nil {
+		return nil // Empty or not JSON: nothing to convert
+	}
+
+	var doc interface{}
+	switch v := body.(type) {
+	case map[string]interface{}:
+		switch {
+		case status >= fiber.StatusBadRequest && format == FormatJSONAPI:
+			doc = jsonAPIErrors(status, v)
+		case status >= fiber.StatusBadRequest:
+			return nil
+		case format == FormatJSONAPI:
+			doc = e.jsonAPIDocument(c, resource, relations, []map[string]interface{}{v}, false, shape)
+		default:
+			doc = e.halResource(c, resource, relations, v, shape.include, shape.project)
+		}
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(v))
+		for _, raw := range v {
+			if item, ok := raw.(map[string]interface{}); ok {
+				items = append(items, item)
+			}
+		}
+		if format == FormatJSONAPI {
+			doc = e.jsonAPIDocument(c, resource, relations, items, true, shape)
+		} else {
+			doc = e.halCollection(c, resource, relations, items, shape)
+		}
+	default:
+		return nil
+	}
+
+	if err := c.Status(status).JSON(doc); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, formatMediaType(format))
+	return nil
+}
+
+// jsonAPIDocument builds a top-level JSON:API document with links, meta and included resources.
+func (e *Engine) jsonAPIDocument(c *fiber.Ctx, resource string, relations []relation, items []map[string]interface{}, list bool, shape responseShape) fiber.Map {
+	data := make([]interface{}, len(items))
+	for i, item := range items {
+		data[i] = e.jsonAPIResource(c, resource, relations, item, shape.sparse[resource])
+	}
+
+	doc := fiber.Map{"links": fiber.Map{"self": c.BaseURL() + c.OriginalURL()}}
+	if list {
+		doc["data"] = data
+		doc["meta"] = fiber.Map{"total": totalCount(c, len(items))}
+		for rel, href := range paginationLinks(c) {
+			doc["links"].(fiber.Map)[rel] = href
+		}
+	} else {
+		doc["data"] = data[0]
+	}
+
+	// Compound document: ?include=author,post
+	if len(shape.include) > 0 {
+		included := make([]interface{}, 0)
+		seen := make(map[string]bool)
+		for _, item := range items {
+			for _, name := range shape.include {
+				rel := findRelation(relations, name)
+				related := e.templateFind(rel.target, item[rel.key])
+				if item[rel.key] == nil || related == nil {
+					continue
+				}
+				key := rel.target + "/" + e.ids.id(rel.target, related)
+				if !seen[key] {
+					seen[key] = true
+					included = append(included, e.jsonAPIResource(c, rel.target, e.relationsOf(rel.target), related, shape.sparse[rel.target]))
+				}
+			}
+		}
+		doc["included"] = included
+	}
+	return doc
+}
+
+// jsonAPIResource converts an item to a JSON:API resource object; its id field becomes the id
+// and foreign keys become relationships. A non-empty fieldset keeps only the listed attributes and relationships.
+func (e *Engine) jsonAPIResource(c *fiber.Ctx, resource string, relations []relation, item map[string]interface{}, fieldset []string) fiber.Map {
+	idField := e.ids.field(resource)
+	id := idString(item[idField])
+	attributes := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		if k != idField {
+			attributes[k] = v
+		}
+	}
+
+	relationships := make(fiber.Map)
+	for _, rel := range relations {
+		value, ok := item[rel.key]
+		if !ok {
+			continue
+		}
+		delete(attributes, rel.key)
+		if value == nil {
+			relationships[rel.name] = fiber.Map{"data": nil}
+			continue
+		}
+		relatedID := idString(value)
+		relationships[rel.name] = fiber.Map{
+			"data":  fiber.Map{"type": rel.target, "id": relatedID},
+			"links": fiber.Map{"related": c.BaseURL() + "/" + rel.target + "/" + url.PathEscape(relatedID)},
+		}
+	}
+
+	if len(fieldset) > 0 {
+		for k := range attributes {
+			if !containsString(fieldset, k) {
+				delete(attributes, k)
+			}
+		}
+		for k := range relationships {
+			if !containsString(fieldset, k) {
+				delete(relationships, k)
+			}
+		}
+	}
+
+	obj := fiber.Map{
+		"type":       resource,
+		"id":         id,
+		"attributes": attributes,
+		"links":      fiber.Map{"self": c.BaseURL() + "/" + resource + "/" + url.PathEscape(id)},
+	}
+	if len(relationships) > 0 {
+		obj["relationships"] = relationships
+	}
+	return obj
+}
+
+// jsonAPIErrors converts an error response ({"error", "message"}) to a JSON:API errors document.
+func jsonAPIErrors(status int, body map[string]interface{}) fiber.Map {
+	errObj := fiber.Map{
+		"status": strconv.Itoa(status),
+		"title":  utils.StatusMessage(status),
+	}
+	if code, ok := body["error"]; ok {
+		errObj["code"] = code
+	}
+	if message, ok := body["message"]; ok {
+		errObj["detail"] = message
+	}
+	if details, ok := body["details"]; ok {
+		errObj["meta"] = fiber.Map{"details": details}
+	}
+	return fiber.Map{"errors": []fiber.Map{errObj}}
+}
+
+// halResource adds _links (self and relations) and requested _embedded resources to an item,
+// projecting its own fields and those of the embedded resources.
+func (e *Engine) halResource(c *fiber.Ctx, resource string, relations []relation, item map[string]interface{}, embed []string, project *projection) fiber.Map {
+	projected := project.apply(item)
+	out := make(fiber.Map, len(projected)+2)
+	for k, v := range projected {
+		out[k] = v
+	}
+
+	links := fiber.Map{
+		"self": fiber.Map{"href": c.BaseURL() + "/" + resource + "/" + url.PathEscape(e.ids.id(resource, item))},
+	}
+	for _, rel := range relations {
+		if value := item[rel.key]; value != nil {
+			links[rel.name] = fiber.Map{"href": c.BaseURL() + "/" + rel.target + "/" + url.PathEscape(idString(value))}
+		}
+	}
+	out["_links"] = links
+
+	// Embedded relations: ?_embed=author,post
+	embedded := make(fiber.Map)
+	for _, name := range embed {
+		rel := findRelation(relations, name)
+		sub, selected := project.sub(rel.name)
+		if !selected || item[rel.key] == nil {
+			continue
+		}
+		if related := e.templateFind(rel.target, item[rel.key]); related != nil {
+			embedded[rel.name] = e.halResource(c, rel.target, e.relationsOf(rel.target), related, nil, sub)
+		}
+	}
+	if len(embedded) > 0 {
+		out["_embedded"] = embedded
+	}
+	return out
+}
+
+// halCollection builds a HAL collection with the items under _embedded and pagination links.
+func (e *Engine) halCollection(c *fiber.Ctx, resource string, relations []relation, items []map[string]interface{}, shape responseShape) fiber.Map {
+	embedded := make([]interface{}, len(items))
+	for i, item := range items {
+		embedded[i] = e.halResource(c, resource, relations, item, shape.include, shape.project)
+	}
+
+	links := fiber.Map{"self": fiber.Map{"href": c.BaseURL() + c.OriginalURL()}}
+	for rel, href := range paginationLinks(c) {
+		links[rel] = fiber.Map{"href": href}
+	}
+
+	return fiber.Map{
+		"_links":    links,
+		"_embedded": fiber.Map{resource: embedded},
+		"count":     len(items),
+		"total":     totalCount(c, len(items)),
+	}
+}
+
+// paginationLinks returns first/prev/next/last links from the pagination headers set by handleGetAll.
+// Cursor pages have first/prev/next links only.
+func paginationLinks(c *fiber.Ctx) map[string]string {
+	links := make(map[string]string)
+	if c.Request().URI().QueryArgs().Has("_cursor") {
+		query, _ := url.ParseQuery(c.Request().URI().QueryArgs().String())
+		link := func(cursor string) string {
+			query.Set("_cursor", cursor)
+			return c.BaseURL() + c.Path() + "?" + query.Encode()
+		}
+		links["first"] = link("")
+		if prev := string(c.Response().Header.Peek("X-Prev-Cursor")); prev != "" {
+			links["prev"] = link(prev)
+		}
+		if next := string(c.Response().Header.Peek("X-Next-Cursor")); next != "" {
+			links["next"] = link(next)
+		}
+		return links
+	}
+	total, err1 := strconv.Atoi(string(c.Response().Header.Peek("X-Total-Count")))
+	limit, err2 := strconv.Atoi(string(c.Response().Header.Peek("X-Limit")))
+	if err1 != nil || err2 != nil || limit <= 0 {
+		return links
+	}
+	page, _ := strconv.Atoi(string(c.Response().Header.Peek("X-Page")))
+	page = max(page, 1)
+	last := max((total+limit-1)/limit, 1)
+
+	query, _ := url.ParseQuery(c.Request().URI().QueryArgs().String())
+	link := func(p int) string {
+		query.Set("_page", strconv.Itoa(p))
+		return c.BaseURL() + c.Path() + "?" + query.Encode()
+	}
+
+	links["first"] = link(1)
+	links["last"] = link(last)
+	if page > 1 {
+		links["prev"] = link(min(page-1, last))
+	}
+	if page < last {
+		links["next"] = link(page + 1)
+	}
+	return links
+}
+
+// totalCount returns the X-Total-Count set by handleGetAll, or n when the header is missing.
+func totalCount(c *fiber.Ctx, n int) int {
+	if total, err := strconv.Atoi(string(c.Response().Header.Peek("X-Total-Count"))); err == nil {
+		return total
+	}
+	return n
+}
+
+// translateJSONAPIQuery rewrites JSON:API query parameters into the native ones:
+// page[number], page[size], page[cursor], sort=-field and filter[field]=value.
+func translateJSONAPIQuery(c *fiber.Ctx) {
+	args := c.Request().URI().QueryArgs()
+	rename := map[string]string{}
+	args.VisitAll(func(key, value []byte) {
+		k := string(key)
+		switch {
+		case k == "page[number]":
+			rename[k] = "_page"
+		case k == "page[size]":
+			rename[k] = "_limit"
+		case k == "page[cursor]":
+			rename[k] = "_cursor"
+		case strings.HasPrefix(k, "filter[") && strings.HasSuffix(k, "]"):
+			rename[k] = k[len("filter[") : len(k)-1]
+		}
+	})
+	for from, to := range rename {
+		value := string(args.Peek(from))
+		args.Del(from)
+		args.Set(to, value)
+	}
+
+	// Only the first sort field is supported
+	if sortParam := string(args.Peek("sort")); sortParam != "" {
+		field, _, _ := strings.Cut(sortParam, ",")
+		order := "asc"
+		if strings.HasPrefix(field, "-") {
+			field, order = field[1:], "desc"
+		}
+		args.Del("sort")
+		args.Set("_sort", field)
+		args.Set("_order", order)
+	}
+}
+
+// jsonAPIFieldsets reads and removes the fields[type] parameters of a JSON:API request.
+func jsonAPIFieldsets(c *fiber.Ctx) map[string][]string {
+	args := c.Request().URI().QueryArgs()
+	sparse := make(map[string][]string)
+	args.VisitAll(func(key, value []byte) {
+		k := string(key)
+		if strings.HasPrefix(k, "fields[") && strings.HasSuffix(k, "]") {
+			sparse[k[len("fields["):len(k)-1]] = splitList(string(value))
+		}
+	})
+	for resource := range sparse {
+		args.Del("fields[" + resource + "]")
+	}
+	return sparse
+}
+
+// decodeFormatBody converts a JSON:API or HAL request body to a plain item.
+// It returns a nil body when the request is already plain JSON.
+func decodeFormatBody(format, resource, idField string, relations []relation, raw []byte, contentType string) (map[string]interface{}, int, error) {
+	parsed, err := parseJSONBody(raw)
+	if err != nil {
+		return nil, fiber.StatusBadRequest, fmt.Errorf("Request body must be valid JSON")
+	}
+	doc, ok := parsed.(map[string]interface{})
+	if !ok {
+		return nil, fiber.StatusBadRequest, fmt.Errorf("Request body must be a JSON object")
+	}
+
+	if format == FormatHAL {
+		delete(doc, "_links")
+		delete(doc, "_embedded")
+		return doc, 0, nil
+	}
+
+	data, ok := doc["data"].(map[string]interface{})
+	if !ok {
+		if strings.HasPrefix(contentType, MIMEJSONAPI) {
+			return nil, fiber.StatusBadRequest, fmt.Errorf("JSON:API documents need a data object")
+		}
+		return nil, 0, nil // Plain JSON sent to a JSON:API server
+	}
+	if t, _ := data["type"].(string); t != "" && t != resource {
+		return nil, fiber.StatusConflict, fmt.Errorf("Resource type '%s' does not match '%s'", t, resource)
+	}
+
+	item := make(map[string]interface{})
+	if attributes, ok := data["attributes"].(map[string]interface{}); ok {
+		for k, v := range attributes {
+			item[k] = v
+		}
+	}
+	if id, ok := data["id"]; ok {
+		item[idField] = id
+	}
+
+	relationships, _ := data["relationships"].(map[string]interface{})
+	for name, raw := range relationships {
+		rel, _ := raw.(map[string]interface{})
+		key := name + "Id"
+		if known := findRelation(relations, name); known != nil {
+			key = known.key
+		}
+		switch linkage := rel["data"].(type) {
+		case nil:
+			item[key] = nil
+		case map[string]interface{}:
+			item[key] = linkage["id"]
+		}
+	}
+	return item, 0, nil
+}
+
+// formatError sends an error response in the negotiated format.
+func formatError(c *fiber.Ctx, format string, status int, code, message string) error {
+	body := fiber.Map{"error": code, "message": message}
+	if format != FormatJSONAPI {
+		return c.Status(status).JSON(body)
+	}
+	if err := c.Status(status).JSON(jsonAPIErrors(status, body)); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, MIMEJSONAPI)
+	return nil
+}
+
+// formatMediaType returns the Content-Type of a response format.
+func formatMediaType(format string) string {
+	switch format {
+	case FormatJSONAPI:
+		return MIMEJSONAPI
+	case FormatHAL:
+		return MIMEHAL
+	}
+	return fiber.MIMEApplicationJSON
+}
+
+// findRelation returns the relation with a name, or nil.
+func findRelation(relations []relation, name string) *relation {
+	for i := range relations {
+		if relations[i].name == name {
+			return &relations[i]
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma-separated parameter, dropping empty entries.
+func splitList(value string) []string {
+	parts := make([]string, 0)
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,689 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphQLName matches valid GraphQL names.
+var graphQLName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// graphQLJSON is a scalar for nested objects and mixed-type values.
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Arbitrary JSON value",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseJSONLiteral,
+})
+
+// graphQLListMetadata holds the total count of a filtered list.
+var graphQLListMetadata = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ListMetadata",
+	Fields: graphql.Fields{
+		"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+// gqlResource describes how a resource is exposed in the GraphQL schema.
+type gqlResource struct {
+	resource   string // Store key, e.g. "users"
+	idField    string // Id field of the items, e.g. "id"
+	typeName   string // Object type, e.g. "User"
+	single     string // Single query, e.g. "user"
+	plural     string // List suffix, e.g. "Users" for allUsers
+	fields     map[string]graphql.Output
+	filterable []string
+	object     *graphql.Object
+}
+
+// gqlRelation links a foreign key field (comments.postId) to its target resource (posts).
+type gqlRelation struct {
+	from, to *gqlResource
+	key      string // Foreign key field, e.g. "postId"
+	name     string // Field on the source type, e.g. "post"
+}
+
+// buildGraphQLSchema generates a GraphQL schema from the resources in the store.
+// It must be called with the store lock held.
+func (e *Engine) buildGraphQLSchema() (graphql.Schema, error) {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		if graphQLName.MatchString(name) && !e.isProxied(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	resources := make(map[string]*gqlResource, len(names))
+	typeNames := schemaNames(names, "JSON", "ListMetadata", "Query", "Mutation", "Subscription")
+	for _, name := range names {
+		typeName := typeNames[name]
+		if !graphQLName.MatchString(typeName) {
+			continue
+		}
+		res := &gqlResource{
+			resource: name,
+			typeName: typeName,
+			single:   strings.ToLower(typeName[:1]) + typeName[1:],
+			plural:   strings.ToUpper(name[:1]) + name[1:],
+			idField:  e.ids.field(name),
+			fields:   make(map[string]graphql.Output),
+		}
+		schema := inferObjectSchema(e.store[name])
+		for field, prop := range schema["properties"].(map[string]interface{}) {
+			if !graphQLName.MatchString(field) {
+				continue
+			}
+			res.fields[field] = graphQLFieldType(field, prop.(map[string]interface{}))
+			if field == res.idField {
+				res.fields[field] = graphql.ID
+			}
+			if isScalarOutput(res.fields[field]) {
+				res.filterable = append(res.filterable, field)
+			}
+		}
+		if _, ok := res.fields[res.idField]; !ok && graphQLName.MatchString(res.idField) {
+			res.fields[res.idField] = graphql.ID
+			res.filterable = append(res.filterable, res.idField)
+		}
+		sort.Strings(res.filterable)
+		resources[name] = res
+	}
+
+	relations := graphQLRelations(resources)
+
+	for _, name := range names {
+		res := resources[name]
+		if res == nil {
+			continue
+		}
+		res.object = graphql.NewObject(graphql.ObjectConfig{
+			Name:   res.typeName,
+			Fields: e.graphQLObjectFields(res, relations),
+		})
+	}
+
+	query := graphql.Fields{
+		"_resources": &graphql.Field{
+			Type:        graphql.NewList(graphql.String),
+			Description: "Names of the available resources",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return names, nil
+			},
+		},
+	}
+	mutation := graphql.Fields{}
+	subscription := graphql.Fields{}
+
+	for _, name := range names {
+		res := resources[name]
+		if res == nil {
+			continue
+		}
+		filter := graphQLFilterInput(res)
+		input := graphQLInput(res)
+		listArgs := graphql.FieldConfigArgument{
+			"filter":  &graphql.ArgumentConfig{Type: filter},
+			"sort":    &graphql.ArgumentConfig{Type: graphql.String, Description: "Field to sort by"},
+			"order":   &graphql.ArgumentConfig{Type: graphql.String, Description: "asc or desc"},
+			"page":    &graphql.ArgumentConfig{Type: graphql.Int, Description: "Page number, starting at 1"},
+			"perPage": &graphql.ArgumentConfig{Type: graphql.Int},
+		}
+		idArgs := graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+		}
+
+		query["all"+res.plural] = &graphql.Field{
+			Type:    graphql.NewList(res.object),
+			Args:    listArgs,
+			Resolve: e.resolveGraphQLList(res),
+		}
+		query["_all"+res.plural+"Meta"] = &graphql.Field{
+			Type: graphQLListMetadata,
+			Args: graphql.FieldConfigArgument{"filter": &graphql.ArgumentConfig{Type: filter}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				items := e.filterGraphQLItems(res.resource, e.templateAll(res.resource), p.Args["filter"])
+				return map[string]interface{}{"count": len(items)}, nil
+			},
+		}
+		query[res.single] = &graphql.Field{
+			Type: res.object,
+			Args: idArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nilIfEmpty(e.templateFind(res.resource, p.Args["id"])), nil
+			},
+		}
+
+		mutation["create"+res.typeName] = &graphql.Field{
+			Type: res.object,
+			Args: graphql.FieldConfigArgument{
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(input)},
+			},
+			Resolve: e.resolveGraphQLCreate(res),
+		}
+		mutation["update"+res.typeName] = &graphql.Field{
+			Type: res.object,
+			Args: graphql.FieldConfigArgument{
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(input)},
+			},
+			Resolve: e.resolveGraphQLUpdate(res),
+		}
+		mutation["delete"+res.typeName] = &graphql.Field{
+			Type:    res.object,
+			Args:    idArgs,
+			Resolve: e.resolveGraphQLDelete(res),
+		}
+
+		subArgs := graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{Type: graphql.ID, Description: "Only events of this item"},
+		}
+		for _, eventType := range []string{EventCreated, EventUpdated, EventDeleted} {
+			subscription[res.single+strings.ToUpper(eventType[:1])+eventType[1:]] = &graphql.Field{
+				Type:      res.object,
+				Args:      subArgs,
+				Subscribe: e.subscribeGraphQL(res, eventType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(ChangeEvent).Item, nil
+				},
+			}
+		}
+		subscription[res.single+"Changed"] = &graphql.Field{
+			Type:      graphQLEventType(res),
+			Args:      subArgs,
+			Subscribe: e.subscribeGraphQL(res, ""),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				ev := p.Source.(ChangeEvent)
+				return map[string]interface{}{"type": ev.Type, "id": ev.ID, "node": ev.Item}, nil
+			},
+		}
+	}
+
+	config := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: query}),
+	}
+	if len(mutation) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutation})
+	}
+	if len(subscription) > 0 {
+		config.Subscription = graphql.NewObject(graphql.ObjectConfig{Name: "Subscription", Fields: subscription})
+	}
+	return graphql.NewSchema(config)
+}
+
+// graphQLRelations derives relations from <name>Id foreign keys that point to an existing resource.
+func graphQLRelations(resources map[string]*gqlResource) []gqlRelation {
+	relations := make([]gqlRelation, 0)
+	exists := func(name string) bool {
+		_, ok := resources[name]
+		return ok
+	}
+	for _, from := range resources {
+		for field := range from.fields {
+			if name, target, ok := foreignKeyTarget(field, exists); ok {
+				relations = append(relations, gqlRelation{from: from, to: resources[target], key: field, name: name})
+			}
+		}
+	}
+	sort.Slice(relations, func(i, j int) bool {
+		return relations[i].from.resource+relations[i].key < relations[j].from.resource+relations[j].key
+	})
+	return relations
+}
+
+// graphQLObjectFields returns a thunk with the scalar and relation fields of a resource type.
+func (e *Engine) graphQLObjectFields(res *gqlResource, relations []gqlRelation) graphql.FieldsThunk {
+	return func() graphql.Fields {
+		fields := graphql.Fields{}
+		for name, t := range res.fields {
+			fields[name] = &graphql.Field{Type: t}
+		}
+
+		for _, rel := range relations {
+			rel := rel
+			// Many-to-one: comment.post
+			if rel.from == res {
+				if _, exists := fields[rel.name]; !exists {
+					fields[rel.name] = &graphql.Field{
+						Type: rel.to.object,
+						Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+							item, _ := p.Source.(map[string]interface{})
+							if item[rel.key] == nil {
+								return nil, nil
+							}
+							return nilIfEmpty(e.templateFind(rel.to.resource, item[rel.key])), nil
+						},
+					}
+				}
+			}
+			// One-to-many: post.comments
+			if rel.to == res {
+				if _, exists := fields[rel.from.resource]; !exists {
+					fields[rel.from.resource] = &graphql.Field{
+						Type: graphql.NewList(rel.from.object),
+						Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+							item, _ := p.Source.(map[string]interface{})
+							id := idString(item[res.idField])
+							related := make([]map[string]interface{}, 0)
+							for _, candidate := range e.templateAll(rel.from.resource) {
+								if candidate[rel.key] != nil && idString(candidate[rel.key]) == id {
+									related = append(related, candidate)
+								}
+							}
+							return related, nil
+						},
+					}
+				}
+			}
+		}
+		return fields
+	}
+}
+
+// graphQLFieldType maps an inferred JSON schema to a GraphQL output type.
+func graphQLFieldType(field string, schema map[string]interface{}) graphql.Output {
+	if field == "id" {
+		return graphql.ID
+	}
+	switch schema["type"] {
+	case "string":
+		return graphql.String
+	case "integer":
+		return graphql.Int
+	case "number":
+		return graphql.Float
+	case "boolean":
+		return graphql.Boolean
+	case "array":
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			if t := graphQLFieldType("", items); isScalarOutput(t) {
+				return graphql.NewList(t)
+			}
+		}
+	}
+	return graphQLJSON
+}
+
+// isScalarOutput reports whether a type is one of the built-in scalars.
+func isScalarOutput(t graphql.Output) bool {
+	switch t {
+	case graphql.ID, graphql.String, graphql.Int, graphql.Float, graphql.Boolean:
+		return true
+	}
+	return false
+}
+
+// graphQLFilterInput builds the filter input of a resource: exact match per scalar field, plus q and ids.
+func graphQLFilterInput(res *gqlResource) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{
+		"q":   &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Full-text search"},
+		"ids": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.ID)},
+	}
+	for _, name := range res.filterable {
+		if _, exists := fields[name]; !exists {
+			fields[name] = &graphql.InputObjectFieldConfig{Type: graphql.String}
+		}
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   res.typeName + "Filter",
+		Fields: fields,
+	})
+}
+
+// graphQLInput builds the create/update input of a resource.
+func graphQLInput(res *gqlResource) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for name, t := range res.fields {
+		input, ok := t.(graphql.Input)
+		if !ok {
+			input = graphQLJSON
+		}
+		fields[name] = &graphql.InputObjectFieldConfig{Type: input}
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   res.typeName + "Input",
+		Fields: fields,
+	})
+}
+
+// resolveGraphQLList resolves allX queries with filtering, sorting and pagination.
+func (e *Engine) resolveGraphQLList(res *gqlResource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		items := e.filterGraphQLItems(res.resource, e.templateAll(res.resource), p.Args["filter"])
+
+		if field, ok := p.Args["sort"].(string); ok && field != "" {
+			desc := strings.EqualFold(fmt.Sprintf("%v", p.Args["order"]), "desc")
+			sort.SliceStable(items, func(i, j int) bool {
+				vi := fmt.Sprintf("%v", items[i][field])
+				vj := fmt.Sprintf("%v", items[j][field])
+				if desc {
+					return vi > vj
+				}
+				return vi < vj
+			})
+		}
+
+		if perPage, ok := p.Args["perPage"].(int); ok && perPage > 0 {
+			page, _ := p.Args["page"].(int)
+			start := 0
+			if page > 0 {
+				start = (page - 1) * perPage
+			}
+			if start > len(items) {
+				start = len(items)
+			}
+			end := min(start+perPage, len(items))
+			items = items[start:end]
+		}
+		return items, nil
+	}
+}
+
+// filterGraphQLItems applies a GraphQL filter argument to a list of items.
+// The q search uses the search index and orders items by relevance.
+func (e *Engine) filterGraphQLItems(resource string, items []map[string]interface{}, raw interface{}) []map[string]interface{} {
+	filter, ok := raw.(map[string]interface{})
+	if !ok || len(filter) == 0 {
+		return items
+	}
+	if q, ok := filter["q"].(string); ok && q != "" {
+		scores := e.search.search(resource, q, nil)
+		items = rankItems(items, e.ids.field(resource), scores, false)
+	}
+
+	filtered := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if matchesGraphQLFilter(item, e.ids.field(resource), filter) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// matchesGraphQLFilter reports whether an item satisfies every condition of a filter;
+// ids are matched against idField.
+func matchesGraphQLFilter(item map[string]interface{}, idField string, filter map[string]interface{}) bool {
+	for key, want := range filter {
+		switch key {
+		case "q":
+			continue // Applied by the search index
+		case "ids":
+			ids, _ := want.([]interface{})
+			id := idString(item[idField])
+			found := false
+			for _, candidate := range ids {
+				if idString(candidate) == id {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			v, ok := item[key]
+			if !ok || fmt.Sprintf("%v", v) != fmt.Sprintf("%v", want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resolveGraphQLCreate adds a new item to the store.
+func (e *Engine) resolveGraphQLCreate(res *gqlResource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		input, _ := normalizeJSON(p.Args["input"]).(map[string]interface{})
+		if input == nil {
+			input = make(map[string]interface{})
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		hook := e.graphQLHook(res, "create", "", input)
+		body, answered, err := hook.before(input)
+		if answered != nil || err != nil {
+			return graphQLHookResult(answered, err)
+		}
+		item, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("input must be an object")
+		}
+		e.ids.assign(res.resource, item, e.takenIDs(res.resource))
+		e.store[res.resource] = append(e.store[res.resource], item)
+		e.publish(EventCreated, res.resource, item)
+		return graphQLHookResult(hook.after(fiber.StatusCreated, item))
+	}
+}
+
+// resolveGraphQLUpdate merges the input into an existing item.
+func (e *Engine) resolveGraphQLUpdate(res *gqlResource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		id := fmt.Sprintf("%v", p.Args["id"])
+		input, _ := normalizeJSON(p.Args["input"]).(map[string]interface{})
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		for i, item := range e.store[res.resource] {
+			if idString(item[res.idField]) == id {
+				hook := e.graphQLHook(res, "patch", id, input)
+				body, answered, err := hook.before(input)
+				if answered != nil || err != nil {
+					return graphQLHookResult(answered, err)
+				}
+				fields, _ := body.(map[string]interface{})
+				updated, _ := (&itemPatch{fields: fields}).apply(item, res.idField)
+				e.store[res.resource][i] = updated
+				e.publish(EventUpdated, res.resource, updated)
+				return graphQLHookResult(hook.after(fiber.StatusOK, updated))
+			}
+		}
+		return nil, fmt.Errorf("%s with id '%s' not found", res.resource, id)
+	}
+}
+
+// resolveGraphQLDelete removes an item and returns it.
+func (e *Engine) resolveGraphQLDelete(res *gqlResource) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		id := fmt.Sprintf("%v", p.Args["id"])
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		items := e.store[res.resource]
+		for i, item := range items {
+			if idString(item[res.idField]) == id {
+				hook := e.graphQLHook(res, "delete", id, nil)
+				if _, answered, err := hook.before(nil); answered != nil || err != nil {
+					return graphQLHookResult(answered, err)
+				}
+				e.store[res.resource] = append(items[:i:i], items[i+1:]...)
+				e.publish(EventDeleted, res.resource, item)
+				if _, err := hook.after(fiber.StatusNoContent, nil); err != nil {
+					return graphQLHookResult(nil, err)
+				}
+				return item, nil
+			}
+		}
+		return nil, fmt.Errorf("%s with id '%s' not found", res.resource, id)
+	}
+}
+
+// graphQLHook prepares the script hooks of a mutation, which see it as a request to the item routes.
+func (e *Engine) graphQLHook(res *gqlResource, action, id string, input map[string]interface{}) *writeHook {
+	request := hookRequest{Method: fiber.MethodPost, Path: "/" + res.resource, Resource: res.resource, Action: action, Body: input}
+	if id != "" {
+		request.Method = fiber.MethodPatch
+		if action == "delete" {
+			request.Method = fiber.MethodDelete
+		}
+		request.Path += "/" + id
+		request.Params = map[string]string{"id": id}
+	}
+	return e.hookWrite(request)
+}
+
+// graphQLHookResult returns the item of a hook response as the result of a mutation.
+// Script errors and error statuses become GraphQL errors.
+func graphQLHookResult(res *hookResponse, err error) (interface{}, error) {
+	if err != nil {
+		logScriptError(err)
+		return nil, err
+	}
+	if res.status >= fiber.StatusBadRequest {
+		_, message := res.errorFields()
+		return nil, errors.New(message)
+	}
+	return res.body, nil
+}
+
+// graphQLEventType is the payload of <resource>Changed subscriptions.
+func graphQLEventType(res *gqlResource) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: res.typeName + "Event",
+		Fields: graphql.Fields{
+			"type": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "created, updated or deleted"},
+			"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"node": &graphql.Field{Type: res.object},
+		},
+	})
+}
+
+// subscribeGraphQL streams store events of a resource, optionally restricted to one event type and id.
+func (e *Engine) subscribeGraphQL(res *gqlResource, eventType string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		id, _ := p.Args["id"].(string)
+		events := e.events.subscribe()
+		out := make(chan interface{})
+
+		go func() {
+			defer close(out)
+			defer e.events.unsubscribe(events)
+			for {
+				select {
+				case <-p.Context.Done():
+					return
+				case ev := <-events:
+					if ev.Type == EventReloaded || !ev.matches(res.resource, id) || (eventType != "" && ev.Type != eventType) {
+						continue
+					}
+					select {
+					case out <- ev:
+					case <-p.Context.Done():
+						return
+					}
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// graphQLRequest is the body of a GraphQL HTTP request.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// handleGraphQL executes GraphQL queries and mutations sent via GET or POST.
+func (e *Engine) handleGraphQL(c *fiber.Ctx) error {
+	var req graphQLRequest
+	if c.Method() == fiber.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			v, err := parseJSONBody([]byte(vars))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"errors": []fiber.Map{{"message": "variables must be valid JSON"}},
+				})
+			}
+			req.Variables, _ = v.(map[string]interface{})
+		}
+	} else if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": []fiber.Map{{"message": "Request body must be valid JSON"}},
+		})
+	}
+
+	if req.Query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": []fiber.Map{{"message": "Must provide query string"}},
+		})
+	}
+
+	e.mu.RLock()
+	schema, schemaErr := e.graphQLSchema, e.graphQLErr
+	e.mu.RUnlock()
+	if schemaErr != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": []fiber.Map{{"message": "GraphQL schema unavailable: " + schemaErr.Error()}},
+		})
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        c.UserContext(),
+	})
+	return c.JSON(result)
+}
+
+// rebuildGraphQLSchema regenerates the schema after the store changed shape.
+// It must be called with the store lock held.
+func (e *Engine) rebuildGraphQLSchema() {
+	schema, err := e.buildGraphQLSchema()
+	if err != nil {
+		e.graphQLSchema, e.graphQLErr = nil, err
+		return
+	}
+	e.graphQLSchema, e.graphQLErr = &schema, nil
+}
+
+// nilIfEmpty avoids returning a typed nil map to the GraphQL executor.
+func nilIfEmpty(item map[string]interface{}) interface{} {
+	if item == nil {
+		return nil
+	}
+	return item
+}
+
+// parseJSONLiteral converts an inline GraphQL literal into a JSON value.
+func parseJSONLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue, *ast.FloatValue:
+		var f float64
+		fmt.Sscan(v.GetValue().(string), &f)
+		return f
+	case *ast.ListValue:
+		list := make([]interface{}, len(v.Values))
+		for i, item := range v.Values {
+			list[i] = parseJSONLiteral(item)
+		}
+		return list
+	case *ast.ObjectValue:
+		obj := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			obj[f.Name.Value] = parseJSONLiteral(f.Value)
+		}
+		return obj
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// harFile is the subset of the HAR 1.2 format used for imports.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method   string `json:"method"`
+		URL      string `json:"url"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int         `json:"status"`
+		Headers []harHeader `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// LoadHAR reads a HAR file and returns its JSON API calls as exchanges.
+// Entries whose response is not JSON (pages, scripts, images...) are skipped.
+func LoadHAR(path string) ([]Exchange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HAR file: %w", err)
+	}
+
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+
+	exchanges := make([]Exchange, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		if !strings.Contains(entry.Response.Content.MimeType, "json") {
+			continue
+		}
+
+		ex, err := entry.exchange()
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %w", i+1, err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, nil
+}
+
+// exchange converts a HAR entry into an Exchange.
+func (entry harEntry) exchange() (Exchange, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return Exchange{}, fmt.Errorf("invalid url: %w", err)
+	}
+
+	ex := Exchange{
+		Method:          strings.ToUpper(entry.Request.Method),
+		Path:            u.Path,
+		Status:          entry.Response.Status,
+		ResponseHeaders: make(map[string]string),
+	}
+	if ex.Path == "" {
+		ex.Path = "/"
+	}
+
+	for k, v := range u.Query() {
+		if ex.Query == nil {
+			ex.Query = make(map[string]string)
+		}
+		ex.Query[k] = v[0]
+	}
+
+	if entry.Request.PostData != nil {
+		ex.RequestBody = []byte(entry.Request.PostData.Text)
+	}
+
+	for _, h := range entry.Response.Headers {
+		// HTTP/2 pseudo headers (":status") are not real headers
+		if !strings.HasPrefix(h.Name, ":") {
+			ex.ResponseHeaders[h.Name] = h.Value
+		}
+	}
+
+	body := entry.Response.Content.Text
+	if entry.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return Exchange{}, fmt.Errorf("invalid base64 response body: %w", err)
+		}
+		body = string(decoded)
+	}
+	ex.ResponseBody = []byte(body)
+
+	return ex, nil
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Id strategies for new items of a resource.
+const (
+	IDUUID          = "uuid"          // Random UUID (v4), the default
+	IDUUIDv7        = "uuidv7"        // Time-ordered UUID (v7)
+	IDULID          = "ulid"          // Time-ordered, 26 characters of Crockford base32
+	IDNanoID        = "nanoid"        // 21 URL-safe random characters
+	IDAutoIncrement = "autoincrement" // Integer after the largest existing integer id
+	IDSlug          = "slug"          // Slug of another field (slug:title), made unique with -2, -3...
+)
+
+// DefaultIDField is the field holding the id of an item unless configured otherwise.
+const DefaultIDField = "id"
+
+// IDStrategy sets where the ids of a resource's items live and how new ones are generated.
+type IDStrategy struct {
+	Field    string // Id field name; DefaultIDField when empty
+	Strategy string // One of the ID* strategies; IDUUID when empty
+	From     string // Field that IDSlug derives the id from
+}
+
+// IDStrategies maps resources to their id strategies; the "*" entry applies to the other resources.
+type IDStrategies map[string]IDStrategy
+
+// Resolve returns the strategy of a resource, completing it from the "*" entry and the defaults.
+func (strategies IDStrategies) Resolve(resource string) IDStrategy {
+	s, fallback := strategies[resource], strategies["*"]
+	if s.Field == "" {
+		s.Field = fallback.Field
+	}
+	if s.Strategy == "" {
+		s.Strategy, s.From = fallback.Strategy, fallback.From
+	}
+	if s.Field == "" {
+		s.Field = DefaultIDField
+	}
+	if s.Strategy == "" {
+		s.Strategy = IDUUID
+	}
+	return s
+}
+
+// idGenerator assigns ids to new items according to per-resource strategies.
+// The strategies never change; the counters are guarded by the store lock.
+type idGenerator struct {
+	strategies IDStrategies
+	last       map[string]int64 // Largest integer id per resource, for IDAutoIncrement
+}
+
+// newIDGenerator creates an id generator from per-resource strategies.
+func newIDGenerator(strategies IDStrategies) *idGenerator {
+	return &idGenerator{strategies: strategies, last: make(map[string]int64)}
+}
+
+// field returns the name of the id field of a resource.
+func (g *idGenerator) field(resource string) string {
+	return g.strategies.Resolve(resource).Field
+}
+
+// id returns the id of an item as it appears in paths, or "" when it has none.
+func (g *idGenerator) id(resource string, item map[string]interface{}) string {
+	return idString(item[g.field(resource)])
+}
+
+// rebuild resets the autoincrement counters to the largest integer ids of a (re)loaded store.
+func (g *idGenerator) rebuild(store map[string][]map[string]interface{}) {
+	g.last = make(map[string]int64, len(store))
+	for resource, items := range store {
+		for _, item := range items {
+			g.observe(resource, item)
+		}
+	}
+}
+
+// observe raises the autoincrement counter of a resource past the id of a stored item.
+func (g *idGenerator) observe(resource string, item map[string]interface{}) {
+	if n, ok := integerID(item[g.field(resource)]); ok && n > g.last[resource] {
+		g.last[resource] = n
+	}
+}
+
+// counters returns a copy of the autoincrement counters, for restore to undo a failed write.
+func (g *idGenerator) counters() map[string]int64 {
+	return maps.Clone(g.last)
+}
+
+// restore puts back counters saved before a write that was undone.
+func (g *idGenerator) restore(counters map[string]int64) {
+	g.last = counters
+}
+
+// assign gives an item without an id a new one, avoiding the ids in taken, and adds it to taken.
+func (g *idGenerator) assign(resource string, item map[string]interface{}, taken map[string]bool) {
+	s := g.strategies.Resolve(resource)
+	if _, hasID := item[s.Field]; hasID {
+		return
+	}
+
+	var id interface{}
+	switch s.Strategy {
+	case IDAutoIncrement:
+		for {
+			g.last[resource]++
+			if !taken[strconv.FormatInt(g.last[resource], 10)] {
+				break
+			}
+		}
+		id = float64(g.last[resource]) // Like the numbers of decoded JSON
+	case IDSlug:
+		base := ""
+		if value, ok := item[s.From]; ok && value != nil {
+			base = strings.Join(tokenize(fmt.Sprintf("%v", value)), "-")
+		}
+		if base == "" {
+			base = newNanoID() // Nothing to derive a slug from
+		}
+		slug := base
+		for n := 2; taken[slug]; n++ {
+			slug = base + "-" + strconv.Itoa(n)
+		}
+		id = slug
+	case IDUUIDv7:
+		id = uuid.Must(uuid.NewV7()).String()
+	case IDULID:
+		id = newULID()
+	case IDNanoID:
+		id = newNanoID()
+	default:
+		id = uuid.New().String()
+	}
+	item[s.Field] = id
+	taken[idString(id)] = true
+}
+
+// takenIDs returns the ids of a resource's items. It must be called with the store lock held.
+func (e *Engine) takenIDs(resource string) map[string]bool {
+	taken := make(map[string]bool, len(e.store[resource]))
+	for _, item := range e.store[resource] {
+		taken[e.ids.id(resource, item)] = true
+	}
+	return taken
+}
+
+// idString formats an id as it appears in paths. Integral numbers are written
+// without an exponent (1000000, not 1e+06).
+func idString(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", id)
+}
+
+// integerID returns the value of an integer id, given as a number or a numeric string.
+func integerID(id interface{}) (int64, bool) {
+	switch v := id.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), true
+		}
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// ulidAlphabet is Crockford's base32, as used by ULIDs.
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID: a 48-bit millisecond timestamp and 80 random bits.
+func newULID() string {
+	var data [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		data[i] = byte(ms)
+		ms >>= 8
+	}
+	rand.Read(data[6:])
+
+	// 128 bits as 26 characters of 5 bits, the first holding the top 3 bits
+	out := make([]byte, 26)
+	hi := uint64(data[0])<<56 | uint64(data[1])<<48 | uint64(data[2])<<40 | uint64(data[3])<<32 |
+		uint64(data[4])<<24 | uint64(data[5])<<16 | uint64(data[6])<<8 | uint64(data[7])
+	lo := uint64(data[8])<<56 | uint64(data[9])<<48 | uint64(data[10])<<40 | uint64(data[11])<<32 |
+		uint64(data[12])<<24 | uint64(data[13])<<16 | uint64(data[14])<<8 | uint64(data[15])
+	for i := 25; i >= 0; i-- {
+		out[i] = ulidAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// nanoIDAlphabet is the URL-safe alphabet of nanoid.
+const nanoIDAlphabet = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// newNanoID returns a 21-character nanoid.
+func newNanoID() string {
+	data := make([]byte, 21)
+	rand.Read(data)
+	for i, b := range data {
+		data[i] = nanoIDAlphabet[b&63]
+	}
+	return string(data)
+}
+
+// ParseIDFlags builds per-resource id strategies from --id values ([resource=]strategy[:from])
+// and --id-field values ([resource=]field). Values without a resource apply to every resource.
+func ParseIDFlags(strategies, fields []string) (IDStrategies, error) {
+	out := make(IDStrategies)
+	for _, value := range strategies {
+		resource, spec, found := strings.Cut(value, "=")
+		if !found {
+			resource, spec = "*", value
+		}
+		name, from, _ := strings.Cut(spec, ":")
+		switch name {
+		case IDUUID, IDUUIDv7, IDULID, IDNanoID, IDAutoIncrement:
+			if from != "" {
+				return nil, fmt.Errorf("id strategy '%s' takes no field", name)
+			}
+		case IDSlug:
+			if from == "" {
+				return nil, fmt.Errorf("id strategy 'slug' needs a field, as in slug:title")
+			}
+		default:
+			return nil, fmt.Errorf("unknown id strategy '%s' (use uuid, uuidv7, ulid, nanoid, autoincrement or slug:<field>)", name)
+		}
+		if resource == "" {
+			return nil, fmt.Errorf("invalid --id '%s': missing resource before '='", value)
+		}
+		s := out[resource]
+		s.Strategy, s.From = name, from
+		out[resource] = s
+	}
+	for _, value := range fields {
+		resource, field, found := strings.Cut(value, "=")
+		if !found {
+			resource, field = "*", value
+		}
+		if resource == "" || field == "" {
+			return nil, fmt.Errorf("invalid --id-field '%s': use <field> or <resource>=<field>", value)
+		}
+		s := out[resource]
+		s.Field = field
+		out[resource] = s
+	}
+	return out, nil
+}
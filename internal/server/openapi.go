@@ -0,0 +1,759 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MiguelVivar/insta-mock/internal/generator"
+	"github.com/gofiber/fiber/v2"
+)
+
+// uuidPattern matches canonical UUID strings.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// emailPattern loosely matches email addresses.
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// listQueryParameters documents the query parameters supported by list endpoints.
+var listQueryParameters = []map[string]interface{}{
+	queryParam("_page", "Page number (1-based), used with _limit", map[string]interface{}{"type": "integer", "minimum": 1}),
+	queryParam("_limit", "Maximum number of items to return", map[string]interface{}{"type": "integer", "minimum": 1}),
+	queryParam("_cursor", "Opaque cursor from X-Next-Cursor/X-Prev-Cursor; empty for the first page", map[string]interface{}{"type": "string"}),
+	queryParam("_sort", "Field to sort by", map[string]interface{}{"type": "string"}),
+	queryParam("_order", "Sort order", map[string]interface{}{"type": "string", "enum": []string{"asc", "desc"}}),
+	filterQueryParameters[0],
+	filterQueryParameters[1],
+	filterQueryParameters[2],
+	queryParam("_score", "Add the relevance of each item to q searches as _score", map[string]interface{}{"type": "boolean"}),
+	queryParam("_distinct", "Return the sorted distinct values of this field instead of items", map[string]interface{}{"type": "string"}),
+	itemQueryParameters[0],
+	itemQueryParameters[1],
+	itemQueryParameters[2],
+}
+
+// filterQueryParameters documents the filters shared by list, count and aggregate endpoints.
+var filterQueryParameters = []map[string]interface{}{
+	queryParam("q", "Full-text search: accent-insensitive words or word prefixes, ranked by relevance", map[string]interface{}{"type": "string"}),
+	queryParam("q_fields", "Comma-separated fields searched by q (default: all)", map[string]interface{}{"type": "string"}),
+	queryParam("_filter", `Filter expression, e.g. (role == "admin" || age > 30) && email ~ "@example.com"`, map[string]interface{}{"type": "string"}),
+}
+
+// itemQueryParameters documents the query parameters that shape returned items.
+var itemQueryParameters = []map[string]interface{}{
+	queryParam("_fields", "Comma-separated fields to return; dot paths select nested fields (address.city)", map[string]interface{}{"type": "string"}),
+	queryParam("_exclude", "Comma-separated fields to leave out; dot paths allowed", map[string]interface{}{"type": "string"}),
+	queryParam("_embed", "Comma-separated relations (postId → post) to embed", map[string]interface{}{"type": "string"}),
+}
+
+// OpenAPIInfo holds the metadata of a generated OpenAPI document.
+type OpenAPIInfo struct {
+	Title     string
+	Version   string
+	ServerURL string
+}
+
+// OpenAPI builds an OpenAPI 3.1 document describing every resource in the store.
+func (e *Engine) OpenAPI(info OpenAPIInfo) map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	resources := make([]string, 0, len(e.store))
+	for name := range e.store {
+		if !e.isProxied(name) {
+			resources = append(resources, name)
+		}
+	}
+	sort.Strings(resources)
+
+	paths := make(map[string]interface{})
+	schemas := make(map[string]interface{})
+	names := schemaNames(resources, "Error")
+	for _, resource := range resources {
+		schemaName := names[resource]
+		schemas[schemaName] = inferObjectSchema(e.store[resource])
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + schemaName}
+
+		filterParams := append(append([]map[string]interface{}{}, filterQueryParameters...), fieldFilterParameters(e.store[resource])...)
+
+		bulkParams := append(append([]map[string]interface{}{}, filterParams...),
+			queryParam("_all", "Apply to every item when no filter is given", map[string]interface{}{"type": "boolean"}))
+
+		paths["/"+resource] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "List " + resource,
+				"operationId": "list" + schemaName,
+				"parameters":  listParameters(e.store[resource]),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("List of "+resource, map[string]interface{}{"type": "array", "items": ref}),
+					"304": map[string]interface{}{"description": "Not modified since If-None-Match or If-Modified-Since"},
+					"400": errorResponse("Invalid query"),
+				},
+			},
+			"patch": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Update every " + resource + " matching the filters",
+				"operationId": "bulkUpdate" + schemaName,
+				"parameters":  bulkParams,
+				"requestBody": patchRequestBody(map[string]interface{}{"type": "object"}),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Per-item results", bulkResultsSchema("updated")),
+					"207": jsonResponse("Per-item results, some of which failed", bulkResultsSchema("updated")),
+					"400": errorResponse("Missing or invalid filter, invalid patch, or a failure with _atomic=true"),
+				},
+			},
+			"delete": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Delete every " + resource + " matching the filters",
+				"operationId": "bulkDelete" + schemaName,
+				"parameters":  bulkParams,
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Per-item results", bulkResultsSchema("deleted")),
+					"400": errorResponse("Missing or invalid filter"),
+				},
+			},
+			"head": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Count " + resource + " in X-Total-Count",
+				"operationId": "head" + schemaName,
+				"parameters":  filterParams,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Matching items in X-Total-Count"},
+				},
+			},
+			"post": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Create " + resource,
+				"operationId": "create" + schemaName,
+				"requestBody": jsonRequestBody(ref),
+				"responses": map[string]interface{}{
+					"201": jsonResponse("Created", ref),
+					"400": errorResponse("Invalid body"),
+				},
+			},
+		}
+
+		idParam := []map[string]interface{}{{
+			"name":        "id",
+			"in":          "path",
+			"required":    true,
+			"description": "Value of the item's " + e.ids.field(resource) + " field",
+			"schema":      map[string]interface{}{"type": "string"},
+		}}
+		ifMatchParam := []map[string]interface{}{headerParam("If-Match", "Only change the item if it still has this ETag")}
+		paths["/"+resource+"/{id}"] = map[string]interface{}{
+			"parameters": idParam,
+			"get": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Get " + resource + " by id",
+				"operationId": "get" + schemaName,
+				"parameters":  append([]map[string]interface{}{headerParam("If-None-Match", "Answer 304 if the item still has one of these ETags")}, itemQueryParameters...),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Found", ref),
+					"304": map[string]interface{}{"description": "Not modified"},
+					"404": errorResponse("Not found"),
+				},
+			},
+			"put": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Replace " + resource,
+				"operationId": "replace" + schemaName,
+				"parameters":  ifMatchParam,
+				"requestBody": jsonRequestBody(ref),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Replaced", ref),
+					"400": errorResponse("Invalid body"),
+					"404": errorResponse("Not found"),
+					"412": errorResponse("If-Match does not match the item's ETag"),
+				},
+			},
+			"patch": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Partially update " + resource,
+				"operationId": "update" + schemaName,
+				"parameters":  ifMatchParam,
+				"requestBody": patchRequestBody(ref),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Updated", ref),
+					"400": errorResponse("Invalid body or patch"),
+					"404": errorResponse("Not found"),
+					"409": errorResponse("A JSON Patch test operation failed"),
+					"412": errorResponse("If-Match does not match the item's ETag"),
+					"422": errorResponse("The patch cannot be applied to the item"),
+				},
+			},
+			"delete": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Delete " + resource,
+				"operationId": "delete" + schemaName,
+				"parameters":  ifMatchParam,
+				"responses": map[string]interface{}{
+					"204": map[string]interface{}{"description": "Deleted"},
+					"404": errorResponse("Not found"),
+					"412": errorResponse("If-Match does not match the item's ETag"),
+				},
+			},
+		}
+		paths["/"+resource+"/_bulk"] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Create many " + resource,
+				"operationId": "bulkCreate" + schemaName,
+				"parameters": []map[string]interface{}{
+					queryParam("_atomic", "Create nothing if any item fails", map[string]interface{}{"type": "boolean"}),
+				},
+				"requestBody": jsonRequestBody(map[string]interface{}{"type": "array", "items": ref}),
+				"responses": map[string]interface{}{
+					"201": jsonResponse("All created", bulkResultsSchema("created")),
+					"207": jsonResponse("Some items failed", bulkResultsSchema("created")),
+					"400": errorResponse("Invalid body, or an item failed with _atomic"),
+				},
+			},
+		}
+		paths["/"+resource+"/_count"] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Count " + resource,
+				"operationId": "count" + schemaName,
+				"parameters":  filterParams,
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Matching items", map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+					}),
+					"400": errorResponse("Invalid query"),
+				},
+			},
+		}
+		paths["/"+resource+"/_aggregate"] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Aggregate " + resource,
+				"operationId": "aggregate" + schemaName,
+				"parameters": append([]map[string]interface{}{
+					queryParam("group", "Comma-separated fields to group by; one row per group", map[string]interface{}{"type": "string"}),
+					queryParam("count", "Include the count (default when no other metric is set)", map[string]interface{}{"type": "boolean"}),
+					queryParam("sum", "Comma-separated numeric fields to sum (sum_<field>)", map[string]interface{}{"type": "string"}),
+					queryParam("avg", "Comma-separated numeric fields to average (avg_<field>)", map[string]interface{}{"type": "string"}),
+					queryParam("min", "Comma-separated fields to take the minimum of (min_<field>)", map[string]interface{}{"type": "string"}),
+					queryParam("max", "Comma-separated fields to take the maximum of (max_<field>)", map[string]interface{}{"type": "string"}),
+				}, filterParams...),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("One row, or one row per group", map[string]interface{}{}),
+					"400": errorResponse("Invalid query"),
+				},
+			},
+		}
+		paths["/"+resource+"/_events"] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{resource},
+				"summary":     "Stream changes to " + resource + " (Server-Sent Events)",
+				"operationId": "stream" + schemaName + "Events",
+				"parameters": []map[string]interface{}{
+					queryParam("id", "Only events of this item", map[string]interface{}{"type": "string"}),
+					{
+						"name":        "Last-Event-ID",
+						"in":          "header",
+						"description": "Resume after this event",
+						"schema":      map[string]interface{}{"type": "string"},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Event stream",
+						"content": map[string]interface{}{
+							"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	schemas["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error":   map[string]interface{}{"type": "string"},
+			"message": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"error", "message"},
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   info.Title,
+			"version": info.Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+	if info.ServerURL != "" {
+		doc["servers"] = []map[string]interface{}{{"url": info.ServerURL}}
+	}
+	if e.spec != nil {
+		mergeSpecDocument(doc, e.spec.Document)
+	}
+	return doc
+}
+
+// mergeSpecDocument adds the paths and components of a served spec to a generated document.
+// The document is OpenAPI 3.1, so the schemas of a 3.0 spec are converted on the way. The
+// spec's info replaces the generated one, noting that the mock added its own routes.
+func mergeSpecDocument(doc, spec map[string]interface{}) {
+	spec = deepCopyJSON(spec).(map[string]interface{}) // The served spec is also used for validation
+	if version, _ := spec["openapi"].(string); strings.HasPrefix(version, "3.0") {
+		upgradeSchemas(spec)
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+	for p, item := range asMap(spec["paths"]) {
+		paths[p] = item
+	}
+
+	components := doc["components"].(map[string]interface{})
+	for section, entries := range asMap(spec["components"]) {
+		target, ok := components[section].(map[string]interface{})
+		if !ok {
+			target = make(map[string]interface{})
+			components[section] = target
+		}
+		for name, v := range asMap(entries) {
+			target[name] = v
+		}
+	}
+
+	if info := asMap(spec["info"]); info != nil {
+		note := "Served by Insta-Mock, which adds the routes of its resources to this API."
+		if description, _ := info["description"].(string); description != "" {
+			note = description + "\n\n" + note
+		}
+		info["description"] = note
+		doc["info"] = info
+	}
+}
+
+// upgradeSchemas converts the OpenAPI 3.0 schemas found in a spec value to their 3.1 (JSON
+// Schema) form, in place: nullable becomes a "null" type, and boolean exclusive bounds become numbers.
+func upgradeSchemas(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			switch key {
+			case "schema":
+				upgradeSchema(asMap(child))
+			case "schemas":
+				for _, schema := range asMap(child) {
+					upgradeSchema(asMap(schema))
+				}
+			case "example", "examples":
+				// Sample data, not schemas
+			default:
+				upgradeSchemas(child)
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			upgradeSchemas(child)
+		}
+	}
+}
+
+// upgradeSchema converts one OpenAPI 3.0 schema and its subschemas to 3.1, in place.
+func upgradeSchema(schema map[string]interface{}) {
+	if schema == nil {
+		return
+	}
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		if t, ok := schema["type"].(string); ok {
+			schema["type"] = []interface{}{t, "null"}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && !slices.Contains(enum, nil) {
+			schema["enum"] = append(enum, nil)
+		}
+	}
+	delete(schema, "nullable")
+	for exclusive, bound := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+		if flag, ok := schema[exclusive].(bool); ok {
+			delete(schema, exclusive)
+			if value, hasBound := schema[bound]; flag && hasBound {
+				schema[exclusive] = value
+				delete(schema, bound)
+			}
+		}
+	}
+
+	for _, key := range []string{"items", "additionalProperties", "not"} {
+		upgradeSchema(asMap(schema[key]))
+	}
+	for _, key := range []string{"properties", "patternProperties"} {
+		for _, sub := range asMap(schema[key]) {
+			upgradeSchema(asMap(sub))
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := schema[key].([]interface{})
+		for _, sub := range list {
+			upgradeSchema(asMap(sub))
+		}
+	}
+}
+
+// handleOpenAPI serves the generated OpenAPI document.
+func (e *Engine) handleOpenAPI(c *fiber.Ctx) error {
+	return c.JSON(e.OpenAPI(OpenAPIInfo{
+		Title:     "Insta-Mock API",
+		Version:   "1.0.0",
+		ServerURL: c.BaseURL(),
+	}))
+}
+
+// schemaName turns a resource name into a component schema name (users -> User).
+func schemaName(resource string) string {
+	name := resource
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		name = name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "ses") || strings.HasSuffix(name, "xes"):
+		name = name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		name = name[:len(name)-1]
+	}
+
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' })
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+	if len(parts) == 0 {
+		return resource // Nothing but separators, such as "-" or "_"
+	}
+	return strings.Join(parts, "")
+}
+
+// schemaNames returns the schema name of each resource. Resources whose names would collide
+// with each other (user and users both make User) or with a reserved name keep their raw
+// name instead, with a numeric suffix in the rare case that is taken too, so schemas and
+// operationIds stay unique.
+func schemaNames(resources []string, reserved ...string) map[string]string {
+	count := make(map[string]int, len(resources))
+	taken := make(map[string]bool, len(resources))
+	for _, name := range reserved {
+		count[name]++
+		taken[name] = true
+	}
+	for _, resource := range resources {
+		count[schemaName(resource)]++
+	}
+	names := make(map[string]string, len(resources))
+	for _, resource := range resources {
+		if name := schemaName(resource); count[name] == 1 {
+			names[resource] = name
+			taken[name] = true
+		}
+	}
+	for _, resource := range resources {
+		if _, ok := names[resource]; ok {
+			continue
+		}
+		name := resource
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s%d", resource, n)
+		}
+		names[resource] = name
+		taken[name] = true
+	}
+	return names
+}
+
+// listParameters returns the common list parameters plus one filter per top-level field.
+func listParameters(items []map[string]interface{}) []map[string]interface{} {
+	params := make([]map[string]interface{}, 0, len(listQueryParameters))
+	params = append(params, listQueryParameters...)
+	return append(params, fieldFilterParameters(items)...)
+}
+
+// fieldFilterParameters documents the exact-match filters on the scalar fields of items.
+func fieldFilterParameters(items []map[string]interface{}) []map[string]interface{} {
+	params := make([]map[string]interface{}, 0)
+	fields := make(map[string]bool)
+	for _, item := range items {
+		for k, v := range item {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				continue
+			}
+			fields[k] = true
+		}
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		params = append(params, queryParam(name, "Filter by exact "+name, map[string]interface{}{"type": "string"}))
+	}
+	return params
+}
+
+// bulkResultsSchema describes the response of a bulk operation, counted under countField.
+func bulkResultsSchema(countField string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			countField: map[string]interface{}{"type": "integer"},
+			"results": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"index":   map[string]interface{}{"type": "integer"},
+						"id":      map[string]interface{}{},
+						"status":  map[string]interface{}{"type": "integer"},
+						"item":    map[string]interface{}{"type": "object"},
+						"error":   map[string]interface{}{"type": "string"},
+						"message": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// headerParam builds an OpenAPI string header parameter.
+func headerParam(name, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "header",
+		"description": description,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+// queryParam builds an OpenAPI query parameter.
+func queryParam(name, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"required":    false,
+		"schema":      schema,
+	}
+}
+
+// jsonResponse builds an OpenAPI response with a JSON body.
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// errorResponse builds an OpenAPI response using the Error schema.
+func errorResponse(description string) map[string]interface{} {
+	return jsonResponse(description, map[string]interface{}{"$ref": "#/components/schemas/Error"})
+}
+
+// jsonRequestBody builds an OpenAPI JSON request body.
+func jsonRequestBody(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// patchRequestBody builds an OpenAPI PATCH request body: plain JSON fields (shallow merge),
+// a JSON Merge Patch or a JSON Patch.
+func patchRequestBody(schema map[string]interface{}) map[string]interface{} {
+	body := jsonRequestBody(schema)
+	content := body["content"].(map[string]interface{})
+	content[MIMEMergePatch] = map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}
+	content[MIMEJSONPatch] = map[string]interface{}{"schema": map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"op", "path"},
+			"properties": map[string]interface{}{
+				"op":    map[string]interface{}{"type": "string", "enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  map[string]interface{}{"type": "string", "description": "JSON Pointer"},
+				"from":  map[string]interface{}{"type": "string", "description": "JSON Pointer (move, copy)"},
+				"value": map[string]interface{}{"description": "Value (add, replace, test)"},
+			},
+		},
+	}}
+	return body
+}
+
+// inferObjectSchema infers an object schema from a set of sample items.
+// Fields present in every item are marked as required.
+func inferObjectSchema(items []map[string]interface{}) map[string]interface{} {
+	samples := make(map[string][]interface{})
+	counts := make(map[string]int)
+	for _, item := range items {
+		for k, v := range item {
+			samples[k] = append(samples[k], v)
+			counts[k]++
+		}
+	}
+
+	properties := make(map[string]interface{}, len(samples))
+	required := make([]string, 0)
+	for field, values := range samples {
+		properties[field] = inferSchema(field, values)
+		if counts[field] == len(items) {
+			required = append(required, field)
+		}
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// inferSchema infers the schema of a field from its sample values.
+func inferSchema(field string, values []interface{}) map[string]interface{} {
+	types := make([]string, 0, 2)
+	addType := func(t string) {
+		for _, existing := range types {
+			if existing == t {
+				return
+			}
+		}
+		types = append(types, t)
+	}
+
+	var objects []map[string]interface{}
+	var elements []interface{}
+	var strs []string
+	for _, v := range values {
+		switch val := v.(type) {
+		case nil:
+			addType("null")
+		case bool:
+			addType("boolean")
+		case float64:
+			if val == math.Trunc(val) {
+				addType("integer")
+			} else {
+				addType("number")
+			}
+		case string:
+			addType("string")
+			strs = append(strs, val)
+		case map[string]interface{}:
+			addType("object")
+			objects = append(objects, val)
+		case []interface{}:
+			addType("array")
+			elements = append(elements, val...)
+		default:
+			addType("string")
+		}
+	}
+
+	// A field seen both as integer and number is a number
+	if containsString(types, "integer") && containsString(types, "number") {
+		filtered := types[:0]
+		for _, t := range types {
+			if t != "integer" {
+				filtered = append(filtered, t)
+			}
+		}
+		types = filtered
+	}
+
+	schema := make(map[string]interface{})
+	switch {
+	case len(types) == 0:
+		return schema
+	case len(types) == 1:
+		schema["type"] = types[0]
+	default:
+		sort.Strings(types)
+		schema["type"] = types
+	}
+
+	if len(objects) > 0 {
+		nested := inferObjectSchema(objects)
+		schema["properties"] = nested["properties"]
+		if req, ok := nested["required"]; ok {
+			schema["required"] = req
+		}
+	}
+	if containsString(types, "array") {
+		if len(elements) > 0 {
+			schema["items"] = inferSchema(field, elements)
+		} else {
+			schema["items"] = map[string]interface{}{}
+		}
+	}
+	if len(strs) > 0 {
+		if format := inferFormat(field, strs); format != "" {
+			schema["format"] = format
+		}
+	}
+	return schema
+}
+
+// inferFormat returns the OpenAPI string format shared by every sample value.
+func inferFormat(field string, values []string) string {
+	fieldType := generator.InferFieldType(field)
+
+	checks := []struct {
+		format string
+		match  func(string) bool
+	}{
+		{"uuid", func(s string) bool { return uuidPattern.MatchString(s) }},
+		{"email", func(s string) bool { return emailPattern.MatchString(s) }},
+		{"date-time", func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil }},
+		{"date", func(s string) bool {
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil || fieldType == generator.FieldTypeDate && len(s) == 10 && strings.Count(s, "-") == 2
+		}},
+		{"uri", func(s string) bool {
+			return (fieldType == generator.FieldTypeURL || fieldType == generator.FieldTypeImage) &&
+				(strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://"))
+		}},
+	}
+
+	for _, check := range checks {
+		all := true
+		for _, v := range values {
+			if !check.match(v) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return check.format
+		}
+	}
+	return ""
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		reserved  []string
+		want      map[string]string
+	}{
+		{"distinct", []string{"users", "categories"}, nil, map[string]string{"users": "User", "categories": "Category"}},
+		{"singular and plural", []string{"user", "users", "posts"}, nil, map[string]string{"user": "user", "users": "users", "posts": "Post"}},
+		{"reserved", []string{"errors"}, []string{"Error"}, map[string]string{"errors": "errors"}},
+		{"raw name taken", []string{"User", "user", "users", "users2"}, nil, map[string]string{"User": "User", "user": "user", "users": "users", "users2": "Users2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaNames(tt.resources, tt.reserved...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schemaNames(%v) = %v, want %v", tt.resources, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAPIOperationIDsAreUnique(t *testing.T) {
+	e := NewEngine(testData(t, `{"user":[{"id":1}],"users":[{"id":1}]}`))
+	doc := e.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1"})
+
+	seen := make(map[string]bool)
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for _, op := range item.(map[string]interface{}) {
+			op, ok := op.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := op["operationId"].(string)
+			if seen[id] {
+				t.Errorf("duplicate operationId %q at %s", id, path)
+			}
+			seen[id] = true
+		}
+	}
+}
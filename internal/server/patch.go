@@ -0,0 +1,348 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Media types of PATCH bodies besides plain JSON (a shallow merge of top-level fields).
+const (
+	MIMEMergePatch = "application/merge-patch+json" // RFC 7396
+	MIMEJSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// patchError is a PATCH body that is malformed (400) or cannot be applied to an item (409, 422).
+type patchError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (err *patchError) Error() string { return err.message }
+
+// send answers the request with the error.
+func (err *patchError) send(c *fiber.Ctx) error {
+	return c.Status(err.status).JSON(fiber.Map{"error": err.code, "message": err.message})
+}
+
+// jsonPatchOp is one RFC 6902 operation.
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"` // Kept raw so that null differs from a missing value
+	value interface{}
+}
+
+// itemPatch is a parsed PATCH body in one of the supported media types.
+type itemPatch struct {
+	mediaType string
+	fields    map[string]interface{} // Plain JSON and merge patch
+	ops       []jsonPatchOp          // JSON Patch
+}
+
+// parseItemPatch parses a PATCH body according to its Content-Type.
+func parseItemPatch(contentType string, body []byte) (*itemPatch, *patchError) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	p := &itemPatch{mediaType: mediaType}
+
+	if mediaType == MIMEJSONPatch {
+		if err := json.Unmarshal(body, &p.ops); err != nil {
+			return nil, &patchError{fiber.StatusBadRequest, "invalid_patch", "JSON Patch body must be an array of operations"}
+		}
+		for i, op := range p.ops {
+			if op.Path == nil {
+				return nil, &patchError{fiber.StatusBadRequest, "invalid_patch", fmt.Sprintf("Operation %d has no path", i)}
+			}
+			switch op.Op {
+			case "add", "replace", "test":
+				if len(op.Value) == 0 {
+					return nil, &patchError{fiber.StatusBadRequest, "invalid_patch", fmt.Sprintf("Operation %d (%s) has no value", i, op.Op)}
+				}
+				json.Unmarshal(op.Value, &p.ops[i].value)
+			case "move", "copy":
+				if op.From == nil {
+					return nil, &patchError{fiber.StatusBadRequest, "invalid_patch", fmt.Sprintf("Operation %d (%s) has no from", i, op.Op)}
+				}
+			case "remove":
+			default:
+				return nil, &patchError{fiber.StatusBadRequest, "invalid_patch", fmt.Sprintf("Operation %d has unknown op '%s'", i, op.Op)}
+			}
+		}
+		return p, nil
+	}
+
+	if err := json.Unmarshal(body, &p.fields); err != nil || p.fields == nil {
+		return nil, &patchError{fiber.StatusBadRequest, "invalid_body", "Request body must be a JSON object"}
+	}
+	return p, nil
+}
+
+// apply returns a patched copy of an item, leaving the item untouched. The id field never changes.
+func (p *itemPatch) apply(item map[string]interface{}, idField string) (map[string]interface{}, *patchError) {
+	var doc interface{}
+	switch p.mediaType {
+	case MIMEMergePatch:
+		doc = mergePatch(deepCopyJSON(item), p.fields)
+	case MIMEJSONPatch:
+		var err *patchError
+		if doc, err = applyJSONPatch(deepCopyJSON(item), p.ops); err != nil {
+			return nil, err
+		}
+	default:
+		out := make(map[string]interface{}, len(item)+len(p.fields))
+		for k, v := range item {
+			out[k] = v
+		}
+		for k, v := range p.fields {
+			out[k] = v
+		}
+		doc = out
+	}
+
+	out, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &patchError{fiber.StatusUnprocessableEntity, "patch_failed", "Patched item must remain a JSON object"}
+	}
+	out[idField] = item[idField]
+	return out, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch: objects merge recursively, null removes a field,
+// and anything else replaces the target.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+		} else {
+			targetObj[k] = mergePatch(targetObj[k], v)
+		}
+	}
+	return targetObj
+}
+
+// applyJSONPatch applies RFC 6902 operations in order; the first failing operation aborts the patch.
+func applyJSONPatch(doc interface{}, ops []jsonPatchOp) (interface{}, *patchError) {
+	for i, op := range ops {
+		fail := func(status int, code, format string, args ...interface{}) *patchError {
+			return &patchError{status, code, fmt.Sprintf("Operation %d (%s %s): %s", i, op.Op, *op.Path, fmt.Sprintf(format, args...))}
+		}
+
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, fail(fiber.StatusBadRequest, "invalid_patch", "%v", err)
+		}
+		var from []string
+		if op.From != nil {
+			if from, err = parsePointer(*op.From); err != nil {
+				return nil, fail(fiber.StatusBadRequest, "invalid_patch", "%v", err)
+			}
+		}
+
+		switch op.Op {
+		case "add":
+			doc, err = pointerAdd(doc, path, deepCopyJSON(op.value))
+		case "remove":
+			doc, _, err = pointerRemove(doc, path)
+		case "replace":
+			if _, err = pointerGet(doc, path); err == nil {
+				if len(path) == 0 {
+					doc = deepCopyJSON(op.value)
+				} else if doc, _, err = pointerRemove(doc, path); err == nil {
+					doc, err = pointerAdd(doc, path, deepCopyJSON(op.value))
+				}
+			}
+		case "move":
+			if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+				return nil, fail(fiber.StatusUnprocessableEntity, "patch_failed", "cannot move a value into itself")
+			}
+			var value interface{}
+			if doc, value, err = pointerRemove(doc, from); err == nil {
+				doc, err = pointerAdd(doc, path, value)
+			}
+		case "copy":
+			var value interface{}
+			if value, err = pointerGet(doc, from); err == nil {
+				doc, err = pointerAdd(doc, path, deepCopyJSON(value))
+			}
+		case "test":
+			var value interface{}
+			if value, err = pointerGet(doc, path); err == nil && !reflect.DeepEqual(value, deepCopyJSON(op.value)) {
+				got, _ := json.Marshal(value)
+				want, _ := json.Marshal(op.value)
+				return nil, fail(fiber.StatusConflict, "test_failed", "value is %s, not %s", got, want)
+			}
+		}
+		if err != nil {
+			return nil, fail(fiber.StatusUnprocessableEntity, "patch_failed", "%v", err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens; "" is the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer '%s' must start with '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// pointerGet returns the value at a pointer.
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path /%s does not exist", strings.Join(path[:i+1], "/"))
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("path /%s does not exist", strings.Join(path[:i+1], "/"))
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds or replaces a value at a pointer and returns the updated document.
+// In arrays it inserts before the index, or appends with "-".
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[key] = value
+			return node, nil
+		case []interface{}:
+			index := len(node)
+			if key != "-" {
+				var err error
+				if index, err = arrayIndex(key, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("parent of /%s is not an object or array", strings.Join(path, "/"))
+	})
+}
+
+// pointerRemove removes the value at a pointer and returns the updated document and the removed value.
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole item")
+	}
+	var removed interface{}
+	doc, err := pointerUpdate(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("path /%s does not exist", strings.Join(path, "/"))
+			}
+			removed = value
+			delete(node, key)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(key, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[index]
+			return append(node[:index], node[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("path /%s does not exist", strings.Join(path, "/"))
+	})
+	return doc, removed, err
+}
+
+// pointerUpdate walks to the parent of a pointer's last token, lets change rebuild it,
+// and stores the result back along the way (arrays may be reallocated).
+func pointerUpdate(doc interface{}, path []string, change func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("path /%s does not exist", path[0])
+		}
+		updated, err := pointerUpdate(child, path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = updated
+		return node, nil
+	case []interface{}:
+		index, err := arrayIndex(path[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := pointerUpdate(node[index], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("path /%s does not exist", path[0])
+}
+
+// arrayIndex parses an array index token, which must be between 0 and last.
+func arrayIndex(token string, last int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("'%s' is not an array index", token)
+	}
+	if index > last {
+		return 0, fmt.Errorf("array index %d is out of bounds", index)
+	}
+	return index, nil
+}
+
+// deepCopyJSON copies a JSON value; numbers come back as float64, like decoded request bodies.
+func deepCopyJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+	return out
+}
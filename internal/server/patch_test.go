@@ -0,0 +1,134 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestItemPatch(t *testing.T) {
+	item := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id":      float64(1),
+			"name":    "Ana",
+			"tags":    []interface{}{"a", "b"},
+			"address": map[string]interface{}{"city": "Lima", "zip": "15001"},
+		}
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        map[string]interface{}
+		status      int    // Expected error status, 0 for success
+		code        string // Expected error code
+	}{
+		{
+			name:        "plain json merges top-level fields",
+			contentType: "application/json",
+			body:        `{"name":"Bea","id":9,"address":{"city":"Cusco"}}`,
+			want:        map[string]interface{}{"id": float64(1), "name": "Bea", "tags": []interface{}{"a", "b"}, "address": map[string]interface{}{"city": "Cusco"}},
+		},
+		{
+			name:        "merge patch deletes nulls and merges objects",
+			contentType: MIMEMergePatch,
+			body:        `{"tags":null,"address":{"zip":null,"street":"Av. Sol"}}`,
+			want:        map[string]interface{}{"id": float64(1), "name": "Ana", "address": map[string]interface{}{"city": "Lima", "street": "Av. Sol"}},
+		},
+		{
+			name:        "json patch move and copy",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"move","from":"/address/city","path":"/city"},{"op":"copy","from":"/tags/0","path":"/tags/-"}]`,
+			want:        map[string]interface{}{"id": float64(1), "name": "Ana", "city": "Lima", "tags": []interface{}{"a", "b", "a"}, "address": map[string]interface{}{"zip": "15001"}},
+		},
+		{
+			name:        "json patch passing test",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"test","path":"/name","value":"Ana"},{"op":"remove","path":"/tags/0"}]`,
+			want:        map[string]interface{}{"id": float64(1), "name": "Ana", "tags": []interface{}{"b"}, "address": map[string]interface{}{"city": "Lima", "zip": "15001"}},
+		},
+		{
+			name:        "json patch failed test",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"replace","path":"/name","value":"Bea"},{"op":"test","path":"/name","value":"Ana"}]`,
+			status:      409,
+			code:        "test_failed",
+		},
+		{
+			name:        "json patch move into itself",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"move","from":"/address","path":"/address/home"}]`,
+			status:      422,
+			code:        "patch_failed",
+		},
+		{
+			name:        "json patch missing path",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"remove","path":"/missing"}]`,
+			status:      422,
+			code:        "patch_failed",
+		},
+		{
+			name:        "json patch replacing the document",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"replace","path":"","value":[1]}]`,
+			status:      422,
+			code:        "patch_failed",
+		},
+		{
+			name:        "json patch without from",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"copy","path":"/x"}]`,
+			status:      400,
+			code:        "invalid_patch",
+		},
+		{
+			name:        "json patch unknown op",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"swap","path":"/x"}]`,
+			status:      400,
+			code:        "invalid_patch",
+		},
+		{
+			name:        "json patch not an array",
+			contentType: MIMEJSONPatch,
+			body:        `{"op":"remove","path":"/name"}`,
+			status:      400,
+			code:        "invalid_patch",
+		},
+		{
+			name:        "merge patch not an object",
+			contentType: MIMEMergePatch,
+			body:        `null`,
+			status:      400,
+			code:        "invalid_body",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := item()
+			p, perr := parseItemPatch(tt.contentType, []byte(tt.body))
+			var got map[string]interface{}
+			if perr == nil {
+				got, perr = p.apply(original, "id")
+			}
+			if tt.status != 0 {
+				if perr == nil {
+					t.Fatalf("patched = %v, want error %d %s", got, tt.status, tt.code)
+				}
+				if perr.status != tt.status || perr.code != tt.code {
+					t.Fatalf("error = %d %s, want %d %s", perr.status, perr.code, tt.status, tt.code)
+				}
+				return
+			}
+			if perr != nil {
+				t.Fatalf("unexpected error %d %s: %s", perr.status, perr.code, perr.message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patched = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(original, item()) {
+				t.Errorf("apply changed the original item: %v", original)
+			}
+		})
+	}
+}
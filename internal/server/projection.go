@@ -0,0 +1,175 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// fieldTree is a set of dot paths grouped by segment; a nil subtree selects the whole field.
+type fieldTree map[string]fieldTree
+
+// newFieldTree builds a tree from paths such as "id", "address.city".
+func newFieldTree(paths []string) fieldTree {
+	root := fieldTree{}
+	for _, path := range paths {
+		node := root
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			if i == len(parts)-1 {
+				node[part] = nil // Whole field, wins over narrower paths
+				break
+			}
+			child, ok := node[part]
+			if ok && child == nil {
+				break // Whole field already selected
+			}
+			if !ok {
+				child = fieldTree{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return root
+}
+
+// pick keeps only the selected paths of a value; arrays are projected element by element.
+func (t fieldTree) pick(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, sub := range t {
+			if val, ok := v[k]; ok {
+				if sub == nil {
+					out[k] = val
+				} else {
+					out[k] = sub.pick(val)
+				}
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = t.pick(elem)
+		}
+		return out
+	}
+	return value
+}
+
+// omit removes the selected paths of a value; arrays are projected element by element.
+func (t fieldTree) omit(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = val
+		}
+		for k, sub := range t {
+			if sub == nil {
+				delete(out, k)
+			} else if val, ok := out[k]; ok {
+				out[k] = sub.omit(val)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = t.omit(elem)
+		}
+		return out
+	}
+	return value
+}
+
+// projection selects the fields of returned items: ?_fields=id,name,address.city and ?_exclude=address.zip
+type projection struct {
+	fields  fieldTree // nil selects every field
+	exclude fieldTree // nil excludes nothing
+}
+
+// parseProjection reads _fields and _exclude; it returns nil when neither is set.
+func parseProjection(c *fiber.Ctx) *projection {
+	fields := splitList(utils.CopyString(c.Query("_fields")))
+	exclude := splitList(utils.CopyString(c.Query("_exclude")))
+	if len(fields) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	p := &projection{}
+	if len(fields) > 0 {
+		p.fields = newFieldTree(fields)
+	}
+	if len(exclude) > 0 {
+		p.exclude = newFieldTree(exclude)
+	}
+	return p
+}
+
+// apply returns the projected copy of an item.
+func (p *projection) apply(item map[string]interface{}) map[string]interface{} {
+	if p == nil {
+		return item
+	}
+	var value interface{} = item
+	if p.fields != nil {
+		value = p.fields.pick(value)
+	}
+	if p.exclude != nil {
+		value = p.exclude.omit(value)
+	}
+	return value.(map[string]interface{})
+}
+
+// sub returns the projection of a nested field, such as an embedded relation.
+// It reports false when the field is not selected at all.
+func (p *projection) sub(name string) (*projection, bool) {
+	if p == nil {
+		return nil, true
+	}
+	sp := &projection{}
+	if p.fields != nil {
+		child, ok := p.fields[name]
+		if !ok {
+			return nil, false
+		}
+		sp.fields = child
+	}
+	if p.exclude != nil {
+		child, ok := p.exclude[name]
+		if ok && child == nil {
+			return nil, false
+		}
+		sp.exclude = child
+	}
+	if sp.fields == nil && sp.exclude == nil {
+		return nil, true
+	}
+	return sp, true
+}
+
+// embedRelations returns a copy of an item with related items embedded under the relation names (?_embed=post).
+// Existing fields with the same name are kept.
+func (e *Engine) embedRelations(item map[string]interface{}, relations []relation, embed []string) map[string]interface{} {
+	if len(embed) == 0 {
+		return item
+	}
+	out := make(map[string]interface{}, len(item)+len(embed))
+	for k, v := range item {
+		out[k] = v
+	}
+	for _, name := range embed {
+		rel := findRelation(relations, name)
+		if _, exists := out[rel.name]; exists || item[rel.key] == nil {
+			continue
+		}
+		if related := e.templateFind(rel.target, item[rel.key]); related != nil {
+			out[rel.name] = related
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+)
+
+// ProxyConfig forwards requests that the mock does not handle to a real backend.
+type ProxyConfig struct {
+	Target        string            // Upstream base URL, e.g. http://localhost:8080
+	Headers       map[string]string // Headers set on every forwarded request
+	RemoveHeaders []string          // Headers stripped from forwarded requests
+	Resources     []string          // Resources forwarded even though they exist in the data file
+}
+
+// ParseHeaderFlag splits a "Name: value" flag into its parts.
+func ParseHeaderFlag(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return "", "", fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(value), nil
+}
+
+// isProxied reports whether a resource is forwarded upstream instead of being mocked.
+func (e *Engine) isProxied(resource string) bool {
+	if e.proxy == nil {
+		return false
+	}
+	for _, r := range e.proxy.Resources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// registerProxyResource forwards every route of a resource to the upstream.
+func (e *Engine) registerProxyResource(resource string) {
+	e.app.All("/"+resource, e.handleProxy)
+	e.app.All("/"+resource+"/*", e.handleProxy)
+}
+
+// handleProxy forwards the request to the upstream, rewriting headers on the way.
+func (e *Engine) handleProxy(c *fiber.Ctx) error {
+	target := strings.TrimRight(e.proxy.Target, "/") + c.OriginalURL()
+
+	for _, name := range e.proxy.RemoveHeaders {
+		c.Request().Header.Del(name)
+	}
+	for name, value := range e.proxy.Headers {
+		c.Request().Header.Set(name, value)
+	}
+	c.Request().Header.Del(fiber.HeaderHost)
+
+	if err := proxy.Do(c, target); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   "proxy_error",
+			"message": fmt.Sprintf("Upstream %s failed: %v", e.proxy.Target, err),
+		})
+	}
+
+	c.Set("X-Mock-Proxied", "true")
+	return nil
+}
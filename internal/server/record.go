@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// RecordConfig holds configuration options for the recorder.
+type RecordConfig struct {
+	Target string // Upstream base URL
+	Redact RedactConfig
+}
+
+// Recorder proxies traffic to an upstream API and captures it as fixtures.
+type Recorder struct {
+	app      *fiber.App
+	config   RecordConfig
+	fixtures *Fixtures
+	mu       sync.Mutex
+	count    int
+	OnRecord func(ex Exchange) // Callback for logging
+}
+
+// NewRecorder creates a recorder forwarding every request to config.Target.
+func NewRecorder(config RecordConfig) *Recorder {
+	r := &Recorder{
+		app: fiber.New(fiber.Config{
+			AppName:               "Insta-Mock Recorder",
+			DisableStartupMessage: true,
+		}),
+		config:   config,
+		fixtures: NewFixtures(config.Redact),
+	}
+
+	r.app.All("/*", r.handleRecord)
+	return r
+}
+
+// handleRecord forwards a request upstream and captures the exchange.
+func (r *Recorder) handleRecord(c *fiber.Ctx) error {
+	target := strings.TrimRight(r.config.Target, "/") + c.OriginalURL()
+	c.Request().Header.Del(fiber.HeaderHost)
+
+	// Capture the request before proxying rewrites it. Fiber reuses its buffers,
+	// so every string must be copied.
+	ex := Exchange{
+		Method:      utils.CopyString(c.Method()),
+		Path:        utils.CopyString(c.Path()),
+		RequestBody: append([]byte(nil), c.Body()...),
+	}
+	for k, v := range c.Queries() {
+		if ex.Query == nil {
+			ex.Query = make(map[string]string)
+		}
+		ex.Query[utils.CopyString(k)] = utils.CopyString(v)
+	}
+
+	if err := proxy.Do(c, target); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   "proxy_error",
+			"message": fmt.Sprintf("Upstream %s failed: %v", r.config.Target, err),
+		})
+	}
+
+	resp := c.Response()
+	ex.Status = resp.StatusCode()
+	ex.ResponseHeaders = make(map[string]string)
+	resp.Header.VisitAll(func(k, v []byte) {
+		ex.ResponseHeaders[string(k)] = string(v)
+	})
+	body, err := resp.BodyUncompressed()
+	if err != nil {
+		body = resp.Body()
+	}
+	ex.ResponseBody = append([]byte(nil), body...)
+
+	r.mu.Lock()
+	r.fixtures.Add(ex)
+	r.count++
+	r.mu.Unlock()
+
+	if r.OnRecord != nil {
+		r.OnRecord(ex)
+	}
+	return nil
+}
+
+// Count returns the number of captured exchanges.
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Save writes the captured collections and stubs to disk.
+func (r *Recorder) Save(dataPath, stubsPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fixtures.Save(dataPath, stubsPath)
+}
+
+// Summary returns the number of captured resources, items and stubs.
+func (r *Recorder) Summary() (resources, items, stubs int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fixtures.Summary()
+}
+
+// Start runs the recorder server.
+func (r *Recorder) Start(addr string) error {
+	return r.app.Listen(addr)
+}
+
+// Shutdown gracefully stops the recorder server.
+func (r *Recorder) Shutdown() error {
+	return r.app.Shutdown()
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// foreignKeyPattern matches <name>Id and <name>_id fields.
+var foreignKeyPattern = regexp.MustCompile(`^(.+?)(Id|_id)$`)
+
+// relation is a to-one link from a foreign key field (comments.postId) to another resource (posts).
+type relation struct {
+	key    string // Foreign key field, e.g. "postId"
+	name   string // Relation name, e.g. "post"
+	target string // Target resource, e.g. "posts"
+}
+
+// foreignKeyTarget returns the relation name and target resource of a <name>Id field,
+// trying the plural forms of <name> against the existing resources.
+func foreignKeyTarget(field string, exists func(resource string) bool) (name, target string, ok bool) {
+	m := foreignKeyPattern.FindStringSubmatch(field)
+	if m == nil {
+		return "", "", false
+	}
+	name = m[1]
+	for _, candidate := range []string{name + "s", name + "es", strings.TrimSuffix(name, "y") + "ies", name} {
+		if exists(candidate) {
+			return name, candidate, true
+		}
+	}
+	return "", "", false
+}
+
+// relationsOf returns the to-one relations of a resource, sorted by name.
+func (e *Engine) relationsOf(resource string) []relation {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	exists := func(name string) bool {
+		_, ok := e.store[name]
+		return ok
+	}
+	seen := make(map[string]bool)
+	relations := make([]relation, 0)
+	for _, item := range e.store[resource] {
+		for field := range item {
+			if seen[field] {
+				continue
+			}
+			seen[field] = true
+			if name, target, ok := foreignKeyTarget(field, exists); ok {
+				relations = append(relations, relation{key: field, name: name, target: target})
+			}
+		}
+	}
+	sort.Slice(relations, func(i, j int) bool { return relations[i].name < relations[j].name })
+	return relations
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScenarioStarted is the state every scenario begins in.
+const ScenarioStarted = "Started"
+
+// scenarioStore tracks the current state of each named scenario.
+type scenarioStore struct {
+	mu     sync.RWMutex
+	states map[string]string
+}
+
+// newScenarioStore creates a store with every scenario referenced by the stubs in its initial state.
+func newScenarioStore(stubs []Stub) *scenarioStore {
+	s := &scenarioStore{states: make(map[string]string)}
+	for _, stub := range stubs {
+		if stub.Scenario != "" {
+			s.states[stub.Scenario] = ScenarioStarted
+		}
+	}
+	return s
+}
+
+// snapshot returns a copy of the current states.
+func (s *scenarioStore) snapshot() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make(map[string]string, len(s.states))
+	for k, v := range s.states {
+		states[k] = v
+	}
+	return states
+}
+
+// set changes the state of a scenario. It returns false for unknown scenarios.
+func (s *scenarioStore) set(name, state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.states[name]; !ok {
+		return false
+	}
+	s.states[name] = state
+	return true
+}
+
+// transition moves a scenario to a new state if it is still in the given one (any state when
+// from is empty), checking and changing it under one lock. It returns false otherwise.
+func (s *scenarioStore) transition(name, from, to string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.states[name]
+	if !ok || (from != "" && current != from) {
+		return false
+	}
+	s.states[name] = to
+	return true
+}
+
+// reset puts every scenario back in its initial state.
+func (s *scenarioStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.states {
+		s.states[name] = ScenarioStarted
+	}
+}
+
+// registerScenarioRoutes adds the admin endpoints for inspecting and resetting scenarios.
+func (e *Engine) registerScenarioRoutes() {
+	e.app.Get("/_scenarios", e.handleListScenarios)
+	e.app.Post("/_scenarios/reset", e.handleResetScenarios)
+	e.app.Put("/_scenarios/:name", e.handleSetScenario)
+}
+
+// handleListScenarios returns every scenario with its current state.
+func (e *Engine) handleListScenarios(c *fiber.Ctx) error {
+	states := e.scenarios.snapshot()
+
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scenarios := make([]fiber.Map, 0, len(names))
+	for _, name := range names {
+		scenarios = append(scenarios, fiber.Map{
+			"name":  name,
+			"state": states[name],
+		})
+	}
+	return c.JSON(scenarios)
+}
+
+// handleResetScenarios puts every scenario back in the "Started" state.
+func (e *Engine) handleResetScenarios(c *fiber.Ctx) error {
+	e.scenarios.reset()
+	return c.JSON(fiber.Map{
+		"status": "ok",
+		"state":  ScenarioStarted,
+	})
+}
+
+// handleSetScenario moves a scenario to the state given in the body.
+func (e *Engine) handleSetScenario(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.State == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_body",
+			"message": "Request body must be JSON with a \"state\" field",
+		})
+	}
+
+	if !e.scenarios.set(name, body.State) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "not_found",
+			"message": fmt.Sprintf("scenario '%s' not found", name),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"name":  name,
+		"state": body.State,
+	})
+}
@@ -0,0 +1,669 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// maxScriptSteps bounds the work a single hook may do, so a runaway loop cannot hang the server.
+const maxScriptSteps = 10_000_000
+
+// Script is a Starlark file attached to a resource.
+// It may define before(request) and after(request, response) functions.
+type Script struct {
+	Resource string
+	Path     string
+	before   *starlark.Function
+	after    *starlark.Function
+}
+
+// ScriptError describes a failure while loading or running a script.
+type ScriptError struct {
+	Script string
+	Hook   string
+	Err    error
+}
+
+func (e *ScriptError) Error() string {
+	msg := e.Err.Error()
+	if evalErr, ok := e.Err.(*starlark.EvalError); ok {
+		msg = evalErr.Backtrace()
+	}
+	if e.Hook == "" {
+		return fmt.Sprintf("%s: %s", e.Script, msg)
+	}
+	return fmt.Sprintf("%s (%s): %s", e.Script, e.Hook, msg)
+}
+
+// LoadScripts loads every <resource>.star file in a directory.
+func LoadScripts(dir string) (map[string]*Script, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.star"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid scripts directory: %w", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("error reading scripts directory: %w", err)
+	}
+
+	scripts := make(map[string]*Script, len(paths))
+	for _, path := range paths {
+		script, err := loadScript(path)
+		if err != nil {
+			return nil, err
+		}
+		scripts[script.Resource] = script
+	}
+	return scripts, nil
+}
+
+// loadScript executes a script file once and keeps its hook functions.
+func loadScript(path string) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ScriptError{Script: path, Err: err}
+	}
+
+	thread := &starlark.Thread{Name: path}
+	thread.SetMaxExecutionSteps(maxScriptSteps)
+
+	predeclared := starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+		"store":  scriptStoreModule,
+	}
+	globals, err := starlark.ExecFile(thread, path, src, predeclared)
+	if err != nil {
+		return nil, &ScriptError{Script: path, Err: err}
+	}
+
+	script := &Script{
+		Resource: strings.TrimSuffix(filepath.Base(path), ".star"),
+		Path:     path,
+	}
+	for name, target := range map[string]**starlark.Function{"before": &script.before, "after": &script.after} {
+		if fn, ok := globals[name]; ok {
+			f, isFn := fn.(*starlark.Function)
+			if !isFn {
+				return nil, &ScriptError{Script: path, Err: fmt.Errorf("%s must be a function", name)}
+			}
+			*target = f
+		}
+	}
+	return script, nil
+}
+
+// withHooks wraps a resource handler with the before/after hooks of its script, if any.
+func (e *Engine) withHooks(resource, action string, handler fiber.Handler) fiber.Handler {
+	script, ok := e.scripts[resource]
+	if !ok {
+		return handler
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := e.scriptRequest(c, resource, action)
+
+		if script.before != nil {
+			result, err := e.callHook(script, "before", script.before, hookContext{}, req)
+			if err != nil {
+				return sendScriptError(c, err)
+			}
+			if result != starlark.None {
+				return e.sendScriptResponse(c, script, result)
+			}
+			if err := applyScriptRequest(c, req); err != nil {
+				return sendScriptError(c, &ScriptError{Script: script.Path, Hook: "before", Err: err})
+			}
+		}
+
+		if err := handler(c); err != nil {
+			return err
+		}
+
+		if script.after != nil {
+			res := scriptResponse(c)
+			if _, err := e.callHook(script, "after", script.after, hookContext{}, req, res); err != nil {
+				return sendScriptError(c, err)
+			}
+			return e.sendScriptResponse(c, script, res)
+		}
+		return nil
+	}
+}
+
+// writeHook runs the hooks of a resource's script around one write made outside the item
+// routes: an item of a bulk request, a /_batch sub-request or a GraphQL mutation.
+// These writes hold the store lock while their hooks run.
+type writeHook struct {
+	e      *Engine
+	script *Script
+	req    *starlark.Dict
+	tx     *batchTx // The batch of a /_batch sub-request
+}
+
+// hookContext tells the store builtins where a hook runs.
+type hookContext struct {
+	storeLocked bool     // The caller already holds the store lock
+	tx          *batchTx // Store writes join this batch: undone on rollback, published on commit
+}
+
+// context returns the context of the hooks of a write, which holds the store lock.
+func (h *writeHook) context() hookContext {
+	return hookContext{storeLocked: true, tx: h.tx}
+}
+
+// hookResponse is the status and body of a write, as given or changed by a hook.
+type hookResponse struct {
+	status int
+	body   interface{}
+}
+
+// hookWrite prepares the hooks of a write, or returns nil when its resource has no script.
+func (e *Engine) hookWrite(r hookRequest) *writeHook {
+	script, ok := e.scripts[r.Resource]
+	if !ok {
+		return nil
+	}
+	return &writeHook{e: e, script: script, req: r.dict()}
+}
+
+// before runs the before hook and returns the request body as the hook left it. When the hook
+// answers the request itself, that response is returned instead and the write must not happen.
+func (h *writeHook) before(body interface{}) (interface{}, *hookResponse, error) {
+	if h == nil || h.script.before == nil {
+		return body, nil, nil
+	}
+	result, err := h.e.callHook(h.script, "before", h.script.before, h.context(), h.req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result != starlark.None {
+		res, status, err := decodeScriptResponse(h.script, result)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &hookResponse{status: status, body: normalizeJSON(res["body"])}, nil
+	}
+
+	v, found, _ := h.req.Get(starlark.String("body"))
+	if !found || v == starlark.None {
+		return body, nil, nil
+	}
+	value, err := fromStarlark(v)
+	if err != nil {
+		return nil, nil, &ScriptError{Script: h.script.Path, Hook: "before", Err: fmt.Errorf("request body: %w", err)}
+	}
+	return normalizeJSON(value), nil, nil
+}
+
+// after runs the after hook on the response of a completed write and returns the response as
+// the hook left it.
+func (h *writeHook) after(status int, body interface{}) (*hookResponse, error) {
+	if h == nil || h.script.after == nil {
+		return &hookResponse{status: status, body: body}, nil
+	}
+	res := starlark.NewDict(3)
+	res.SetKey(starlark.String("status"), starlark.MakeInt(status))
+	res.SetKey(starlark.String("headers"), starlark.NewDict(0))
+	res.SetKey(starlark.String("body"), toStarlark(body))
+	if _, err := h.e.callHook(h.script, "after", h.script.after, h.context(), h.req, res); err != nil {
+		return nil, err
+	}
+
+	out, status, err := decodeScriptResponse(h.script, res)
+	if err != nil {
+		return nil, err
+	}
+	return &hookResponse{status: status, body: normalizeJSON(out["body"])}, nil
+}
+
+// errorFields returns the error code and message of a rejecting hook response, taken from
+// its body when that is an error object.
+func (r *hookResponse) errorFields() (string, string) {
+	code, message := "rejected", fmt.Sprintf("Rejected by a script hook with status %d", r.status)
+	if body, ok := r.body.(map[string]interface{}); ok {
+		if s, ok := body["error"].(string); ok {
+			code = s
+		}
+		if s, ok := body["message"].(string); ok {
+			message = s
+		}
+	}
+	return code, message
+}
+
+// callHook runs a hook function in a fresh, step-limited thread.
+func (e *Engine) callHook(script *Script, hook string, fn *starlark.Function, ctx hookContext, args ...starlark.Value) (starlark.Value, error) {
+	thread := &starlark.Thread{
+		Name: script.Path + ":" + hook,
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Printf("  📜 %s: %s\n", script.Resource, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxScriptSteps)
+	thread.SetLocal("engine", e)
+	thread.SetLocal("hookContext", ctx)
+
+	result, err := starlark.Call(thread, fn, args, nil)
+	if err != nil {
+		return nil, &ScriptError{Script: script.Path, Hook: hook, Err: err}
+	}
+	return result, nil
+}
+
+// scriptRequest builds the mutable request dict passed to hooks.
+func (e *Engine) scriptRequest(c *fiber.Ctx, resource, action string) *starlark.Dict {
+	body, _ := parseJSONBody(c.Body())
+	return hookRequest{
+		Method:   c.Method(),
+		Path:     c.Path(),
+		Resource: resource,
+		Action:   action,
+		Params:   c.AllParams(),
+		Query:    c.Queries(),
+		Headers:  requestHeaders(c),
+		Body:     body,
+	}.dict()
+}
+
+// hookRequest is a request as hooks see it.
+type hookRequest struct {
+	Method   string
+	Path     string
+	Resource string
+	Action   string
+	Params   map[string]string
+	Query    map[string]string
+	Headers  map[string]string
+	Body     interface{}
+}
+
+// dict builds the mutable request dict of a hook.
+func (r hookRequest) dict() *starlark.Dict {
+	values := func(m map[string]string) starlark.Value {
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[k] = v
+		}
+		return toStarlark(out)
+	}
+
+	req := starlark.NewDict(8)
+	req.SetKey(starlark.String("method"), starlark.String(r.Method))
+	req.SetKey(starlark.String("path"), starlark.String(r.Path))
+	req.SetKey(starlark.String("resource"), starlark.String(r.Resource))
+	req.SetKey(starlark.String("action"), starlark.String(r.Action))
+	req.SetKey(starlark.String("params"), values(r.Params))
+	req.SetKey(starlark.String("query"), values(r.Query))
+	req.SetKey(starlark.String("headers"), values(r.Headers))
+	req.SetKey(starlark.String("body"), toStarlark(r.Body))
+	return req
+}
+
+// requestHeaders returns the first value of each header of a request.
+func requestHeaders(c *fiber.Ctx) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range c.GetReqHeaders() {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+	return headers
+}
+
+// applyScriptRequest writes the body of the request dict back to the request when the hook
+// changed it. The Content-Type is kept, so patch documents still dispatch on their media type.
+func applyScriptRequest(c *fiber.Ctx, req *starlark.Dict) error {
+	v, found, _ := req.Get(starlark.String("body"))
+	if !found || v == starlark.None {
+		return nil
+	}
+	body, err := fromStarlark(v)
+	if err != nil {
+		return fmt.Errorf("request body: %w", err)
+	}
+	original, _ := parseJSONBody(c.Body())
+	if body = normalizeJSON(body); reflect.DeepEqual(body, original) {
+		return nil
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("request body: %w", err)
+	}
+	c.Request().SetBody(data)
+	if len(c.Request().Header.ContentType()) == 0 {
+		c.Request().Header.SetContentType(fiber.MIMEApplicationJSON)
+	}
+	return nil
+}
+
+// scriptResponse builds the mutable response dict passed to after hooks.
+func scriptResponse(c *fiber.Ctx) *starlark.Dict {
+	headers := make(map[string]interface{})
+	c.Response().Header.VisitAll(func(k, v []byte) {
+		if !strings.EqualFold(string(k), fiber.HeaderContentLength) {
+			headers[string(k)] = string(v)
+		}
+	})
+	body, _ := parseJSONBody(c.Response().Body())
+
+	res := starlark.NewDict(3)
+	res.SetKey(starlark.String("status"), starlark.MakeInt(c.Response().StatusCode()))
+	res.SetKey(starlark.String("headers"), toStarlark(headers))
+	res.SetKey(starlark.String("body"), toStarlark(body))
+	return res
+}
+
+// sendScriptResponse writes a response dict returned or modified by a hook.
+func (e *Engine) sendScriptResponse(c *fiber.Ctx, script *Script, v starlark.Value) error {
+	res, status, err := decodeScriptResponse(script, v)
+	if err != nil {
+		return sendScriptError(c, err)
+	}
+	if headers, ok := res["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			c.Set(k, fmt.Sprintf("%v", v))
+		}
+	}
+	c.Status(status)
+
+	body, hasBody := res["body"]
+	if !hasBody || body == nil {
+		return c.Send(nil)
+	}
+	return c.JSON(body)
+}
+
+// decodeScriptResponse converts a response dict returned or modified by a hook, with its status.
+func decodeScriptResponse(script *Script, v starlark.Value) (map[string]interface{}, int, error) {
+	value, err := fromStarlark(v)
+	if err != nil {
+		return nil, 0, &ScriptError{Script: script.Path, Err: fmt.Errorf("response: %w", err)}
+	}
+	res, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, 0, &ScriptError{Script: script.Path, Err: fmt.Errorf("response must be a dict, got %s", v.Type())}
+	}
+
+	status := fiber.StatusOK
+	if s, ok := res["status"].(int64); ok {
+		status = int(s)
+	}
+	return res, status, nil
+}
+
+// sendScriptError reports a script failure to the client and the console.
+func sendScriptError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(scriptErrorBody(err))
+}
+
+// scriptErrorBody logs a script failure and returns its error response body.
+func scriptErrorBody(err error) fiber.Map {
+	logScriptError(err)
+
+	resp := fiber.Map{
+		"error":   "script_error",
+		"message": err.Error(),
+	}
+	if se, ok := err.(*ScriptError); ok {
+		resp["script"] = se.Script
+		resp["hook"] = se.Hook
+	}
+	return resp
+}
+
+// logScriptError reports a script failure on the console.
+func logScriptError(err error) {
+	fmt.Printf("  ❌ Script error: %v\n", err)
+}
+
+// scriptStoreModule exposes a small, lock-safe API over the engine store to scripts.
+// The engine is taken from the calling thread, so scripts can be loaded before it exists.
+var scriptStoreModule = starlarkstruct.FromStringDict(starlark.String("store"), starlark.StringDict{
+	"all": starlark.NewBuiltin("store.all", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		e := scriptEngine(thread)
+		var resource string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "resource", &resource); err != nil {
+			return nil, err
+		}
+		unlock := scriptLock(thread, e, false)
+		defer unlock()
+		list := make([]interface{}, len(e.store[resource]))
+		for i, item := range e.store[resource] {
+			list[i] = item
+		}
+		return toStarlark(list), nil
+	}),
+	"find": starlark.NewBuiltin("store.find", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		e := scriptEngine(thread)
+		var resource string
+		var id starlark.Value
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "resource", &resource, "id", &id); err != nil {
+			return nil, err
+		}
+		unlock := scriptLock(thread, e, false)
+		defer unlock()
+		want := scriptID(id)
+		for _, item := range e.store[resource] {
+			if e.ids.id(resource, item) == want {
+				return toStarlark(item), nil
+			}
+		}
+		return starlark.None, nil
+	}),
+	"insert": starlark.NewBuiltin("store.insert", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		e := scriptEngine(thread)
+		var resource string
+		var item *starlark.Dict
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "resource", &resource, "item", &item); err != nil {
+			return nil, err
+		}
+		value, err := fromStarlark(item)
+		if err != nil {
+			return nil, err
+		}
+		m := value.(map[string]interface{})
+
+		unlock := scriptLock(thread, e, true)
+		defer unlock()
+		publish := scriptChange(thread, e, resource)
+		e.ids.assign(resource, m, e.takenIDs(resource))
+		e.store[resource] = append(e.store[resource], m)
+		publish(EventCreated, m)
+		return toStarlark(m), nil
+	}),
+	"update": starlark.NewBuiltin("store.update", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		e := scriptEngine(thread)
+		var resource string
+		var id starlark.Value
+		var fields *starlark.Dict
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "resource", &resource, "id", &id, "fields", &fields); err != nil {
+			return nil, err
+		}
+		value, err := fromStarlark(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		unlock := scriptLock(thread, e, true)
+		defer unlock()
+		want := scriptID(id)
+		field := e.ids.field(resource)
+		for i, item := range e.store[resource] {
+			if idString(item[field]) == want {
+				// Replace the item rather than change it, as handlers may hold the stored map
+				updated, _ := (&itemPatch{fields: value.(map[string]interface{})}).apply(item, field)
+				publish := scriptChange(thread, e, resource)
+				e.store[resource][i] = updated
+				publish(EventUpdated, updated)
+				return toStarlark(updated), nil
+			}
+		}
+		return starlark.None, nil
+	}),
+	"delete": starlark.NewBuiltin("store.delete", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		e := scriptEngine(thread)
+		var resource string
+		var id starlark.Value
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "resource", &resource, "id", &id); err != nil {
+			return nil, err
+		}
+
+		unlock := scriptLock(thread, e, true)
+		defer unlock()
+		want := scriptID(id)
+		items := e.store[resource]
+		for i, item := range items {
+			if e.ids.id(resource, item) == want {
+				publish := scriptChange(thread, e, resource)
+				e.store[resource] = append(items[:i:i], items[i+1:]...)
+				publish(EventDeleted, item)
+				return starlark.True, nil
+			}
+		}
+		return starlark.False, nil
+	}),
+})
+
+// scriptEngine returns the engine running the current hook.
+func scriptEngine(thread *starlark.Thread) *Engine {
+	return thread.Local("engine").(*Engine)
+}
+
+// scriptContext returns the context of the running hook.
+func scriptContext(thread *starlark.Thread) hookContext {
+	ctx, _ := thread.Local("hookContext").(hookContext)
+	return ctx
+}
+
+// scriptChange prepares a store write of a hook and returns the function that announces its
+// change. Inside a batch, the resource is snapshotted for rollback and the event waits for commit.
+func scriptChange(thread *starlark.Thread, e *Engine, resource string) func(eventType string, item map[string]interface{}) {
+	if tx := scriptContext(thread).tx; tx != nil {
+		tx.save(resource)
+		return func(eventType string, item map[string]interface{}) {
+			tx.record(eventType, resource, item)
+		}
+	}
+	return func(eventType string, item map[string]interface{}) {
+		e.publish(eventType, resource, item)
+	}
+}
+
+// scriptLock takes the store lock for a store call and returns its unlock function. Hooks of
+// bulk writes, batches and GraphQL mutations run with the lock already held, so nothing is taken.
+func scriptLock(thread *starlark.Thread, e *Engine, write bool) func() {
+	if scriptContext(thread).storeLocked {
+		return func() {}
+	}
+	if write {
+		e.mu.Lock()
+		return e.mu.Unlock
+	}
+	e.mu.RLock()
+	return e.mu.RUnlock
+}
+
+// scriptID converts a Starlark id argument to the string form used by the store.
+func scriptID(v starlark.Value) string {
+	if s, ok := v.(starlark.String); ok {
+		return string(s)
+	}
+	return v.String()
+}
+
+// toStarlark converts a decoded JSON value into a Starlark value.
+func toStarlark(v interface{}) starlark.Value {
+	switch val := v.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(val)
+	case string:
+		return starlark.String(val)
+	case int:
+		return starlark.MakeInt(val)
+	case int64:
+		return starlark.MakeInt64(val)
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return starlark.MakeInt64(int64(val))
+		}
+		return starlark.Float(val)
+	case []interface{}:
+		list := make([]starlark.Value, len(val))
+		for i, item := range val {
+			list[i] = toStarlark(item)
+		}
+		return starlark.NewList(list)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(val))
+		for _, k := range keys {
+			dict.SetKey(starlark.String(k), toStarlark(val[k]))
+		}
+		return dict
+	default:
+		return starlark.String(fmt.Sprintf("%v", val))
+	}
+}
+
+// fromStarlark converts a Starlark value back into a JSON-compatible Go value.
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch val := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(val), nil
+	case starlark.String:
+		return string(val), nil
+	case starlark.Int:
+		if i, ok := val.Int64(); ok {
+			return i, nil
+		}
+		return nil, fmt.Errorf("integer %s out of range", val)
+	case starlark.Float:
+		return float64(val), nil
+	case *starlark.List, starlark.Tuple:
+		iter := starlark.Iterate(val)
+		defer iter.Done()
+		list := make([]interface{}, 0)
+		var item starlark.Value
+		for iter.Next(&item) {
+			converted, err := fromStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, val.Len())
+		for _, kv := range val.Items() {
+			key, ok := kv[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", kv[0].Type())
+			}
+			converted, err := fromStarlark(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(key)] = converted
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %s", v.Type())
+	}
+}
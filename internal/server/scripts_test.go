@@ -0,0 +1,108 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHookedPatchKeepsContentType(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.star"), []byte("def before(req):\n    return None\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := LoadScripts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        map[string]interface{}
+	}{
+		{
+			name:        "json patch",
+			contentType: MIMEJSONPatch,
+			body:        `[{"op":"replace","path":"/name","value":"b"}]`,
+			want:        map[string]interface{}{"id": float64(1), "name": "b", "tags": []interface{}{"x"}},
+		},
+		{
+			name:        "merge patch deletes nulls",
+			contentType: MIMEMergePatch,
+			body:        `{"tags":null}`,
+			want:        map[string]interface{}{"id": float64(1), "name": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngineWithConfig(testData(t, `{"users":[{"id":1,"name":"a","tags":["x"]}]}`), EngineConfig{Scripts: scripts})
+			status, body := testRequest(t, e, "PATCH", "/users/1", tt.contentType, tt.body)
+			if status != 200 {
+				t.Fatalf("status = %d, body %v", status, body)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchRollbackUndoesHookWrites(t *testing.T) {
+	dir := t.TempDir()
+	script := "def before(req):\n    store.insert(\"logs\", {\"action\": req[\"action\"]})\n    store.update(\"users\", 1, {\"seen\": True})\n    return None\n"
+	if err := os.WriteFile(filepath.Join(dir, "orders.star"), []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := LoadScripts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngineWithConfig(testData(t, `{"orders":[],"logs":[],"users":[{"id":1,"name":"a"}]}`), EngineConfig{Scripts: scripts})
+
+	status, body := testRequest(t, e, "POST", "/_batch", "application/json",
+		`[{"method":"POST","path":"/orders","body":{"total":3}},{"method":"DELETE","path":"/orders/missing"}]`)
+	if status != 400 {
+		t.Fatalf("status = %d, body %v; want the batch to fail", status, body)
+	}
+	store := e.GetStore()
+	if len(store["logs"]) != 0 || len(store["orders"]) != 0 {
+		t.Errorf("logs = %v, orders = %v; want the hook's insert rolled back", store["logs"], store["orders"])
+	}
+	if _, seen := store["users"][0]["seen"]; seen {
+		t.Errorf("users[0] = %v; want the hook's update rolled back", store["users"][0])
+	}
+	if v := e.versions.item("users", "1").version; v != 1 {
+		t.Errorf("users/1 version = %d; want no change event published", v)
+	}
+}
+
+func TestBulkAfterHookFailureIsReportedPerItem(t *testing.T) {
+	dir := t.TempDir()
+	script := "def after(req, res):\n    if res[\"body\"][\"name\"] == \"bad\":\n        fail(\"rejected\")\n"
+	if err := os.WriteFile(filepath.Join(dir, "users.star"), []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := LoadScripts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngineWithConfig(testData(t, `{"users":[]}`), EngineConfig{Scripts: scripts})
+
+	status, body := testRequest(t, e, "POST", "/users/_bulk?_atomic=true", "application/json", `[{"name":"ok"},{"name":"bad"}]`)
+	if status != 207 {
+		t.Fatalf("status = %d, body %v, want 207", status, body)
+	}
+	results := body.(map[string]interface{})["results"].([]interface{})
+	if got := results[0].(map[string]interface{})["status"]; got != float64(201) {
+		t.Errorf("results[0].status = %v, want 201", got)
+	}
+	if got := results[1].(map[string]interface{}); got["status"] != float64(500) || got["error"] != "script_error" {
+		t.Errorf("results[1] = %v, want a 500 script_error", got)
+	}
+	if n := len(e.GetStore()["users"]); n != 2 {
+		t.Errorf("stored %d users, want both writes kept", n)
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// prefixWeight scales the score of a term matched by prefix (garc → garcia) against an exact match.
+const prefixWeight = 0.5
+
+// searchIndex is an inverted index of the text of a resource's items.
+type searchIndex struct {
+	postings map[string]map[string]map[string]int // Token → item id → field path → occurrences
+	docs     map[string]map[string][]string       // Item id → field path → tokens, to remove an item
+	terms    []string                             // Sorted tokens for prefix lookups; nil when stale
+}
+
+// searchIndexes holds the search index of every resource. It has its own lock, so
+// it can be updated while the store lock is held and queried without it.
+type searchIndexes struct {
+	mu        sync.Mutex
+	resources map[string]*searchIndex
+}
+
+// newSearchIndexes creates an empty set of indexes.
+func newSearchIndexes() *searchIndexes {
+	return &searchIndexes{resources: make(map[string]*searchIndex)}
+}
+
+// rebuild indexes every item of the store from scratch.
+func (s *searchIndexes) rebuild(store map[string][]map[string]interface{}, ids *idGenerator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resources = make(map[string]*searchIndex, len(store))
+	for resource, items := range store {
+		idx := s.index(resource)
+		for _, item := range items {
+			idx.add(ids.id(resource, item), item)
+		}
+	}
+}
+
+// update applies a change event (created, updated or deleted) to the index of a resource.
+func (s *searchIndexes) update(eventType, resource, id string, item map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.index(resource)
+	idx.remove(id)
+	if eventType != EventDeleted {
+		idx.add(id, item)
+	}
+}
+
+// index returns the index of a resource, creating it if needed. The lock must be held.
+func (s *searchIndexes) index(resource string) *searchIndex {
+	idx, ok := s.resources[resource]
+	if !ok {
+		idx = &searchIndex{
+			postings: make(map[string]map[string]map[string]int),
+			docs:     make(map[string]map[string][]string),
+		}
+		s.resources[resource] = idx
+	}
+	return idx
+}
+
+// search scores the items of a resource against a query. Every query token must match a
+// token of the item, exactly or as a prefix; fields limits matching to these field paths
+// (and their nested fields). A query without words matches nothing.
+func (s *searchIndexes) search(resource, query string, fields []string) map[string]float64 {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return map[string]float64{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.index(resource)
+	total := float64(len(idx.docs))
+	var scores map[string]float64
+	for _, token := range tokens {
+		tokenScores := make(map[string]float64)
+		for _, term := range idx.prefixed(token) {
+			weight := 1.0
+			if term != token {
+				weight = prefixWeight
+			}
+			docs := idx.postings[term]
+			idf := math.Log(1 + total/float64(len(docs)))
+			for id, counts := range docs {
+				for field, n := range counts {
+					if matchesFieldPath(field, fields) {
+						tokenScores[id] += weight * idf * float64(n)
+					}
+				}
+			}
+		}
+
+		// Items must match every token
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for id := range scores {
+			if score, ok := tokenScores[id]; ok {
+				scores[id] += score
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
+
+// add indexes an item under its id.
+func (idx *searchIndex) add(id string, item map[string]interface{}) {
+	doc := make(map[string][]string)
+	indexValue("", item, doc)
+	idx.docs[id] = doc
+	for field, tokens := range doc {
+		for _, token := range tokens {
+			if idx.postings[token] == nil {
+				idx.postings[token] = make(map[string]map[string]int)
+				idx.terms = nil
+			}
+			if idx.postings[token][id] == nil {
+				idx.postings[token][id] = make(map[string]int)
+			}
+			idx.postings[token][id][field]++
+		}
+	}
+}
+
+// remove drops an item from the index.
+func (idx *searchIndex) remove(id string) {
+	for _, tokens := range idx.docs[id] {
+		for _, token := range tokens {
+			delete(idx.postings[token], id)
+			if len(idx.postings[token]) == 0 {
+				delete(idx.postings, token)
+				idx.terms = nil
+			}
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// prefixed returns the indexed tokens starting with a prefix, including the prefix itself.
+func (idx *searchIndex) prefixed(prefix string) []string {
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+	start := sort.SearchStrings(idx.terms, prefix)
+	end := start
+	for end < len(idx.terms) && strings.HasPrefix(idx.terms[end], prefix) {
+		end++
+	}
+	return idx.terms[start:end]
+}
+
+// indexValue collects the tokens of a value by field path; nested objects use dot paths
+// (address.city) and array elements share the path of the array.
+func indexValue(path string, value interface{}, doc map[string][]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if path != "" {
+				k = path + "." + k
+			}
+			indexValue(k, val, doc)
+		}
+	case []interface{}:
+		for _, val := range v {
+			indexValue(path, val, doc)
+		}
+	case nil:
+	default:
+		doc[path] = append(doc[path], tokenize(fmt.Sprintf("%v", v))...)
+	}
+}
+
+// tokenize splits text into lowercase words with diacritics removed ("García" → "garcia").
+func tokenize(text string) []string {
+	folded := make([]rune, 0, len(text))
+	for _, r := range norm.NFD.String(text) {
+		if unicode.Is(unicode.Mn, r) {
+			continue // Combining accent
+		}
+		folded = append(folded, unicode.ToLower(r))
+	}
+	return strings.FieldsFunc(string(folded), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// matchesFieldPath reports whether a field path is one of fields or nested in one; empty fields match all.
+func matchesFieldPath(path string, fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, field := range fields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// rankItems keeps the items with a search score, ordered by descending score; idField names
+// the id field the scores are keyed by. With withScore, each item is copied with its score in a _score field.
+func rankItems(items []map[string]interface{}, idField string, scores map[string]float64, withScore bool) []map[string]interface{} {
+	ranked := make([]map[string]interface{}, 0, len(scores))
+	for _, item := range items {
+		score, ok := scores[idString(item[idField])]
+		if !ok {
+			continue
+		}
+		if withScore {
+			scored := make(map[string]interface{}, len(item)+1)
+			for k, v := range item {
+				scored[k] = v
+			}
+			scored["_score"] = math.Round(score*1000) / 1000
+			item = scored
+		}
+		ranked = append(ranked, item)
+	}
+	score := func(item map[string]interface{}) float64 {
+		return scores[idString(item[idField])]
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return score(ranked[i]) > score(ranked[j]) })
+	return ranked
+}
@@ -0,0 +1,598 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MiguelVivar/insta-mock/internal/generator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// specMethods are the OpenAPI path item keys that describe operations.
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// specPathParam matches {param} placeholders in OpenAPI paths.
+var specPathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Spec is a parsed OpenAPI 3 document served as a mock.
+type Spec struct {
+	Document   map[string]interface{}
+	Operations []SpecOperation
+}
+
+// SpecOperation is a single path + method of an OpenAPI document.
+type SpecOperation struct {
+	Method     string
+	Path       string // OpenAPI path, e.g. /users/{id}
+	FiberPath  string // Fiber route, e.g. /users/:id
+	Operation  map[string]interface{}
+	Parameters []map[string]interface{}
+}
+
+// LoadSpec reads an OpenAPI 3 document in YAML or JSON format.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spec: %w", err)
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	doc, ok := normalizeJSON(stringifyYAMLKeys(raw)).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid spec: expected an object at the top level")
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		return nil, fmt.Errorf("invalid spec: only OpenAPI 3.x documents are supported")
+	}
+
+	spec := &Spec{Document: doc}
+	paths, _ := doc["paths"].(map[string]interface{})
+	pathNames := make([]string, 0, len(paths))
+	for p := range paths {
+		pathNames = append(pathNames, p)
+	}
+	// Static segments before parameters, so /users/me wins over /users/{id}
+	sort.Slice(pathNames, func(i, j int) bool {
+		ci, cj := strings.Count(pathNames[i], "{"), strings.Count(pathNames[j], "{")
+		if ci != cj {
+			return ci < cj
+		}
+		return pathNames[i] < pathNames[j]
+	})
+
+	for _, p := range pathNames {
+		item, _ := paths[p].(map[string]interface{})
+		shared := specParameters(item["parameters"], doc)
+		for _, method := range specMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			spec.Operations = append(spec.Operations, SpecOperation{
+				Method:     strings.ToUpper(method),
+				Path:       p,
+				FiberPath:  specFiberPath(p),
+				Operation:  op,
+				Parameters: mergeSpecParameters(shared, specParameters(op["parameters"], doc)),
+			})
+		}
+	}
+	return spec, nil
+}
+
+// stringifyYAMLKeys converts YAML maps with non-string keys (e.g. unquoted
+// response codes like 200) into JSON-compatible maps.
+func stringifyYAMLKeys(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = stringifyYAMLKeys(item)
+		}
+		return val
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprintf("%v", k)] = stringifyYAMLKeys(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range val {
+			val[i] = stringifyYAMLKeys(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// specFiberPath converts /users/{id} into /users/:id.
+func specFiberPath(p string) string {
+	return specPathParam.ReplaceAllStringFunc(p, func(m string) string {
+		return ":" + specParamName(m[1:len(m)-1])
+	})
+}
+
+// specParamName converts an OpenAPI parameter name into a valid Fiber parameter name.
+func specParamName(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// specParameters resolves the parameter list of a path item or operation.
+func specParameters(raw interface{}, doc map[string]interface{}) []map[string]interface{} {
+	list, _ := raw.([]interface{})
+	params := make([]map[string]interface{}, 0, len(list))
+	for _, p := range list {
+		if m, ok := p.(map[string]interface{}); ok {
+			params = append(params, generator.ResolveSchemaRef(m, doc))
+		}
+	}
+	return params
+}
+
+// mergeSpecParameters combines path-level and operation-level parameters; operation ones win.
+func mergeSpecParameters(shared, own []map[string]interface{}) []map[string]interface{} {
+	merged := make([]map[string]interface{}, 0, len(shared)+len(own))
+	merged = append(merged, own...)
+	for _, s := range shared {
+		overridden := false
+		for _, o := range own {
+			if o["name"] == s["name"] && o["in"] == s["in"] {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// registerSpecRoutes registers a route for every operation of the spec.
+func (e *Engine) registerSpecRoutes() {
+	for i := range e.spec.Operations {
+		op := &e.spec.Operations[i]
+		e.app.Add(op.Method, op.FiberPath, e.handleSpecOperation(op))
+	}
+}
+
+// handleSpecOperation validates the request against the operation and answers with an example or generated body.
+// A "Prefer: code=404" header selects a specific documented response.
+func (e *Engine) handleSpecOperation(op *SpecOperation) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if problems := e.validateSpecRequest(c, op); len(problems) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "validation_error",
+				"message": fmt.Sprintf("Request does not match %s %s", op.Method, op.Path),
+				"details": problems,
+			})
+		}
+
+		code, response := e.selectSpecResponse(op, preferredCode(c.Get("Prefer")))
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			status = fiber.StatusOK
+		}
+
+		body, hasBody := e.specResponseBody(response)
+		c.Status(status)
+		if !hasBody {
+			return c.Send(nil)
+		}
+		return c.JSON(body)
+	}
+}
+
+// preferredCode extracts code=NNN from a Prefer header.
+func preferredCode(prefer string) string {
+	for _, part := range strings.Split(prefer, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok && k == "code" {
+			return v
+		}
+	}
+	return ""
+}
+
+// selectSpecResponse picks the preferred response, or the lowest 2xx, or "default".
+func (e *Engine) selectSpecResponse(op *SpecOperation, preferred string) (string, map[string]interface{}) {
+	responses, _ := op.Operation["responses"].(map[string]interface{})
+	resolve := func(code string) map[string]interface{} {
+		r, _ := responses[code].(map[string]interface{})
+		return generator.ResolveSchemaRef(r, e.spec.Document)
+	}
+
+	if preferred != "" {
+		if _, ok := responses[preferred]; ok {
+			return preferred, resolve(preferred)
+		}
+	}
+
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return code, resolve(code)
+		}
+	}
+	if _, ok := responses["default"]; ok {
+		return "200", resolve("default")
+	}
+	return "200", nil
+}
+
+// specResponseBody returns the example of a JSON response or a body generated from its schema.
+func (e *Engine) specResponseBody(response map[string]interface{}) (interface{}, bool) {
+	content, _ := response["content"].(map[string]interface{})
+	media := jsonMediaType(content)
+	if media == nil {
+		return nil, false
+	}
+
+	if example, ok := media["example"]; ok {
+		return example, true
+	}
+	if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		names := make([]string, 0, len(examples))
+		for name := range examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		ex := generator.ResolveSchemaRef(asMap(examples[names[0]]), e.spec.Document)
+		if value, ok := ex["value"]; ok {
+			return value, true
+		}
+	}
+	if schema, ok := media["schema"].(map[string]interface{}); ok {
+		return generator.GenerateFromSchema(schema, e.spec.Document), true
+	}
+	return nil, false
+}
+
+// jsonMediaType returns the JSON media type object of a content map.
+func jsonMediaType(content map[string]interface{}) map[string]interface{} {
+	if m, ok := content["application/json"].(map[string]interface{}); ok {
+		return m
+	}
+	for mediaType, m := range content {
+		if strings.Contains(mediaType, "json") {
+			return asMap(m)
+		}
+	}
+	return nil
+}
+
+// asMap returns v as a JSON object, or nil.
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// validateSpecRequest checks parameters and body against the operation and returns every problem found.
+func (e *Engine) validateSpecRequest(c *fiber.Ctx, op *SpecOperation) []string {
+	problems := make([]string, 0)
+	root := e.spec.Document
+
+	for _, param := range op.Parameters {
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+		schema := generator.ResolveSchemaRef(asMap(param["schema"]), root)
+
+		var raws []string
+		var present bool
+		switch in {
+		case "path":
+			raws = []string{c.Params(specParamName(name))}
+			present, required = true, true
+		case "query":
+			for _, v := range c.Context().QueryArgs().PeekMulti(name) {
+				raws = append(raws, string(v))
+			}
+			present = len(raws) > 0
+		case "header":
+			raws = []string{c.Get(name)}
+			present = raws[0] != ""
+		default:
+			continue
+		}
+
+		if !present {
+			if required {
+				problems = append(problems, fmt.Sprintf("%s parameter '%s' is required", in, name))
+			}
+			continue
+		}
+		problems = append(problems, validateSchema(fmt.Sprintf("%s parameter '%s'", in, name), paramValue(raws, param, schema, root), schema, root)...)
+	}
+
+	body := generator.ResolveSchemaRef(asMap(op.Operation["requestBody"]), root)
+	if body == nil {
+		return problems
+	}
+	required, _ := body["required"].(bool)
+	media := jsonMediaType(asMap(body["content"]))
+
+	if len(c.Body()) == 0 {
+		if required {
+			problems = append(problems, "request body is required")
+		}
+		return problems
+	}
+	if media == nil {
+		return problems
+	}
+	value, err := parseJSONBody(c.Body())
+	if err != nil {
+		return append(problems, "request body must be valid JSON")
+	}
+	if schema, ok := media["schema"].(map[string]interface{}); ok {
+		problems = append(problems, validateSchema("body", value, schema, root)...)
+	}
+	return problems
+}
+
+// paramValue builds the value of a parameter from its raw strings, as its style serialises it.
+// Arrays come from repeated query keys (style form, explode true, the query default) or from one
+// delimited string, and each element is coerced with the items schema.
+func paramValue(raws []string, param, schema, root map[string]interface{}) interface{} {
+	if schemaTypeOf(schema) != "array" {
+		return coerceParam(raws[0], schema)
+	}
+
+	style, _ := param["style"].(string)
+	if style == "" {
+		style = "simple"
+		if param["in"] == "query" {
+			style = "form"
+		}
+	}
+	explode, ok := param["explode"].(bool)
+	if !ok {
+		explode = style == "form"
+	}
+	parts := raws
+	if !explode || style != "form" {
+		sep := ","
+		switch style {
+		case "spaceDelimited":
+			sep = " "
+		case "pipeDelimited":
+			sep = "|"
+		}
+		parts = nil
+		for _, raw := range raws {
+			parts = append(parts, strings.Split(raw, sep)...)
+		}
+	}
+
+	items := generator.ResolveSchemaRef(asMap(schema["items"]), root)
+	list := make([]interface{}, len(parts))
+	for i, part := range parts {
+		list[i] = coerceParam(part, items)
+	}
+	return list
+}
+
+// coerceParam converts a raw parameter string into the type declared by its schema.
+func coerceParam(raw string, schema map[string]interface{}) interface{} {
+	switch schemaTypeOf(schema) {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// schemaTypeOf returns the first non-null type of a schema.
+func schemaTypeOf(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// validateSchema validates a value against a schema subset: type, enum, required,
+// properties, items, numeric and length bounds, pattern, format, $ref and oneOf/anyOf/allOf.
+func validateSchema(path string, value interface{}, schema map[string]interface{}, root map[string]interface{}) []string {
+	schema = generator.ResolveSchemaRef(schema, root)
+	if len(schema) == 0 {
+		return nil
+	}
+	problems := make([]string, 0)
+
+	if parts, ok := schema["allOf"].([]interface{}); ok {
+		for _, p := range parts {
+			problems = append(problems, validateSchema(path, value, asMap(p), root)...)
+		}
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		options, ok := schema[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matches := 0
+		for _, o := range options {
+			if len(validateSchema(path, value, asMap(o), root)) == 0 {
+				matches++
+			}
+		}
+		if matches == 0 || key == "oneOf" && matches > 1 {
+			problems = append(problems, fmt.Sprintf("%s must match %s one of the %s schemas", path, map[bool]string{true: "exactly", false: "at least"}[key == "oneOf"], key))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprintf("%v", allowed) == fmt.Sprintf("%v", value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s must be one of %v", path, enum))
+		}
+	}
+
+	if !matchesSchemaType(value, schema) {
+		return append(problems, fmt.Sprintf("%s must be of type %v", path, schema["type"]))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, _ := r.(string); name != "" {
+					if _, exists := v[name]; !exists {
+						problems = append(problems, fmt.Sprintf("%s.%s is required", path, name))
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, propSchema := range properties {
+			if propValue, exists := v[name]; exists {
+				problems = append(problems, validateSchema(path+"."+name, propValue, asMap(propSchema), root)...)
+			}
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			problems = append(problems, fmt.Sprintf("%s must have at least %v items", path, min))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			problems = append(problems, fmt.Sprintf("%s must have at most %v items", path, max))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, validateSchema(fmt.Sprintf("%s[%d]", path, i), item, items, root)...)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			problems = append(problems, fmt.Sprintf("%s must be >= %v", path, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			problems = append(problems, fmt.Sprintf("%s must be <= %v", path, max))
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && v <= min {
+			problems = append(problems, fmt.Sprintf("%s must be > %v", path, min))
+		}
+		if max, ok := schema["exclusiveMaximum"].(float64); ok && v >= max {
+			problems = append(problems, fmt.Sprintf("%s must be < %v", path, max))
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			problems = append(problems, fmt.Sprintf("%s must be at least %v characters", path, min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			problems = append(problems, fmt.Sprintf("%s must be at most %v characters", path, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				problems = append(problems, fmt.Sprintf("%s must match pattern %s", path, pattern))
+			}
+		}
+		if format, ok := schema["format"].(string); ok && !matchesFormat(v, format) {
+			problems = append(problems, fmt.Sprintf("%s must be a valid %s", path, format))
+		}
+	}
+	return problems
+}
+
+// matchesSchemaType reports whether a value has one of the types allowed by a schema.
+func matchesSchemaType(value interface{}, schema map[string]interface{}) bool {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return true
+	}
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		types = append(types, "null")
+	}
+
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && v == math.Trunc(v) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesFormat validates the common string formats; unknown formats always pass.
+func matchesFormat(value, format string) bool {
+	switch format {
+	case "uuid":
+		_, err := uuid.Parse(value)
+		return err == nil
+	case "email":
+		_, err := mail.ParseAddress(value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	}
+	return true
+}
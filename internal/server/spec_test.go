@@ -0,0 +1,70 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParamValue(t *testing.T) {
+	strings := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	integers := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}}
+	tests := []struct {
+		name   string
+		raws   []string
+		param  map[string]interface{}
+		schema map[string]interface{}
+		want   interface{}
+	}{
+		{"scalar", []string{"5"}, map[string]interface{}{"in": "query"}, map[string]interface{}{"type": "integer"}, float64(5)},
+		{"single query value", []string{"a"}, map[string]interface{}{"in": "query"}, strings, []interface{}{"a"}},
+		{"repeated query keys", []string{"a", "b"}, map[string]interface{}{"in": "query"}, strings, []interface{}{"a", "b"}},
+		{"form without explode", []string{"1,2"}, map[string]interface{}{"in": "query", "explode": false}, integers, []interface{}{float64(1), float64(2)}},
+		{"pipe delimited", []string{"a|b"}, map[string]interface{}{"in": "query", "style": "pipeDelimited"}, strings, []interface{}{"a", "b"}},
+		{"simple header", []string{"1,2"}, map[string]interface{}{"in": "header"}, integers, []interface{}{float64(1), float64(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paramValue(tt.raws, tt.param, tt.schema, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paramValue(%v) = %#v, want %#v", tt.raws, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecArrayQueryParameter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	spec := `openapi: 3.0.3
+info: {title: Pets, version: "1"}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: tags, in: query, schema: {type: array, items: {type: string}}}
+        - {name: ids, in: query, explode: false, schema: {type: array, items: {type: integer}}}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              example: []
+`
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngineWithConfig(map[string]interface{}{}, EngineConfig{Spec: loaded})
+
+	for _, query := range []string{"tags=a", "tags=a&tags=b", "ids=1,2"} {
+		if status, body := testRequest(t, e, "GET", "/pets?"+query, "", ""); status != 200 {
+			t.Errorf("GET /pets?%s = %d %v, want 200", query, status, body)
+		}
+	}
+	if status, _ := testRequest(t, e, "GET", "/pets?ids=1,x", "", ""); status != 400 {
+		t.Errorf("GET /pets?ids=1,x = %d, want 400", status)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// sseHeartbeat is the interval of keep-alive comments, which also detect closed connections.
+const sseHeartbeat = 15 * time.Second
+
+// registerEventRoutes registers the global and per-resource Server-Sent Events streams.
+func (e *Engine) registerEventRoutes() {
+	e.app.Get("/_events", e.handleEvents)
+	e.app.Get("/:resource/_events", e.handleEvents)
+}
+
+// handleEvents streams store changes as Server-Sent Events.
+// Clients resume with the Last-Event-ID header (or ?lastEventId=) from the in-memory event log.
+// Filters: ?id=1 (per-resource stream), ?resource=users (global stream)
+func (e *Engine) handleEvents(c *fiber.Ctx) error {
+	// Copied: the stream outlives the request buffers
+	resource := utils.CopyString(c.Params("resource", c.Query("resource")))
+	id := utils.CopyString(c.Query("id"))
+
+	if e.isProxied(resource) {
+		return c.Next()
+	}
+	if resource != "" {
+		e.mu.RLock()
+		_, exists := e.store[resource]
+		e.mu.RUnlock()
+		if !exists {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": fmt.Sprintf("Resource '%s' not found", resource),
+			})
+		}
+	}
+
+	lastID := c.Get("Last-Event-ID", c.Query("lastEventId"))
+	var since uint64
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_event_id",
+				"message": "Last-Event-ID must be an event id sent by this stream",
+			})
+		}
+		since = n
+	}
+
+	// Without Last-Event-ID only new events are sent
+	var backlog []ChangeEvent
+	var events chan ChangeEvent
+	if lastID != "" {
+		backlog, events = e.events.subscribeSince(since)
+	} else {
+		events = e.events.subscribe()
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer e.events.unsubscribe(events)
+
+		// Tell the client how long to wait before reconnecting
+		fmt.Fprint(w, "retry: 2000\n\n")
+		if w.Flush() != nil {
+			return
+		}
+
+		for _, ev := range backlog {
+			if ev.matches(resource, id) && writeSSE(w, ev) != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case ev := <-events:
+				if !ev.matches(resource, id) {
+					continue
+				}
+				if writeSSE(w, ev) != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// writeSSE writes one event, named after its type and identified by its sequence number.
+func writeSSE(w *bufio.Writer, ev ChangeEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+	return w.Flush()
+}
@@ -0,0 +1,605 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultStubPriority is used for stubs that do not declare a priority.
+const defaultStubPriority = 5
+
+// Stub describes a canned response returned when a request matches its predicates.
+type Stub struct {
+	ID       string       `json:"id,omitempty"`
+	Priority int          `json:"priority,omitempty"` // Lower wins, defaults to 5
+	Request  StubRequest  `json:"request"`
+	Response StubResponse `json:"response"`
+
+	// Scenario state machine: the stub only matches while the scenario is in
+	// RequiredState, and moves it to NewState after responding.
+	Scenario      string `json:"scenario,omitempty"`
+	RequiredState string `json:"requiredState,omitempty"`
+	NewState      string `json:"newState,omitempty"`
+}
+
+// StubRequest holds the predicates a request must satisfy for a stub to apply.
+type StubRequest struct {
+	Method      string             `json:"method,omitempty"`      // Empty or "ANY" matches every method
+	Path        string             `json:"path,omitempty"`        // Exact path, supports :params and trailing *
+	PathPattern string             `json:"pathPattern,omitempty"` // Regular expression on the path
+	Headers     map[string]Matcher `json:"headers,omitempty"`
+	Query       map[string]Matcher `json:"query,omitempty"`
+	Body        []BodyMatcher      `json:"body,omitempty"`
+
+	pathRe *regexp.Regexp
+}
+
+// StubResponse is the response sent back when a stub matches.
+type StubResponse struct {
+	Status   int               `json:"status,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     interface{}       `json:"body,omitempty"`     // Sent as JSON
+	Text     string            `json:"text,omitempty"`     // Sent verbatim when Body is empty
+	DelayMs  int               `json:"delayMs,omitempty"`  // Artificial latency
+	Template bool              `json:"template,omitempty"` // Render strings as Go templates
+}
+
+// Matcher compares a single string value (header, query param, JSON value).
+// A plain JSON string is shorthand for {"equalTo": "..."}.
+type Matcher struct {
+	EqualTo  *string `json:"equalTo,omitempty"`
+	Contains string  `json:"contains,omitempty"`
+	Matches  string  `json:"matches,omitempty"` // Regular expression
+	Absent   bool    `json:"absent,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// BodyMatcher is a predicate on the request body.
+type BodyMatcher struct {
+	EqualToJSON interface{}            `json:"equalToJson,omitempty"` // Deep equality with the parsed body
+	Partial     map[string]interface{} `json:"partial,omitempty"`     // Body must contain this object
+	JSONPath    string                 `json:"jsonPath,omitempty"`    // Value at path must exist (and match Value)
+	Value       *Matcher               `json:"value,omitempty"`       // Optional matcher for JSONPath
+	Matches     string                 `json:"matches,omitempty"`     // Regular expression on the raw body
+
+	re *regexp.Regexp
+}
+
+// UnmarshalJSON accepts either a matcher object or a plain string.
+func (m *Matcher) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		m.EqualTo = &s
+		return nil
+	}
+	type plain Matcher
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*m = Matcher(p)
+	return nil
+}
+
+// stubFile is the on-disk format of a stubs file.
+type stubFile struct {
+	Stubs []Stub `json:"stubs"`
+}
+
+// LoadStubs reads stub definitions from a JSON file.
+// The file may contain either an array of stubs or an object with a "stubs" key.
+func LoadStubs(path string) ([]Stub, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stubs file: %w", err)
+	}
+
+	var stubs []Stub
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &stubs)
+	} else {
+		var f stubFile
+		err = json.Unmarshal(data, &f)
+		stubs = f.Stubs
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid stubs JSON: %w", err)
+	}
+
+	if err := prepareStubs(stubs); err != nil {
+		return nil, err
+	}
+	return stubs, nil
+}
+
+// prepareStubs compiles regular expressions, assigns ids and sorts stubs by priority.
+func prepareStubs(stubs []Stub) error {
+	for i := range stubs {
+		s := &stubs[i]
+		if s.ID == "" {
+			s.ID = "stub-" + strconv.Itoa(i+1)
+		}
+		if s.Priority == 0 {
+			s.Priority = defaultStubPriority
+		}
+		if s.Request.PathPattern != "" {
+			re, err := regexp.Compile(s.Request.PathPattern)
+			if err != nil {
+				return fmt.Errorf("stub %s: invalid pathPattern: %w", s.ID, err)
+			}
+			s.Request.pathRe = re
+		}
+		for name, m := range s.Request.Headers {
+			if err := m.compile(); err != nil {
+				return fmt.Errorf("stub %s: header %s: %w", s.ID, name, err)
+			}
+			s.Request.Headers[name] = m
+		}
+		for name, m := range s.Request.Query {
+			if err := m.compile(); err != nil {
+				return fmt.Errorf("stub %s: query %s: %w", s.ID, name, err)
+			}
+			s.Request.Query[name] = m
+		}
+		for j := range s.Request.Body {
+			if err := s.Request.Body[j].compile(); err != nil {
+				return fmt.Errorf("stub %s: body matcher %d: %w", s.ID, j+1, err)
+			}
+		}
+	}
+
+	sort.SliceStable(stubs, func(i, j int) bool {
+		return stubs[i].Priority < stubs[j].Priority
+	})
+	return nil
+}
+
+// compile prepares the regular expression of a matcher.
+func (m *Matcher) compile() error {
+	if m.Matches == "" {
+		return nil
+	}
+	re, err := regexp.Compile(m.Matches)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	m.re = re
+	return nil
+}
+
+// compile prepares the regular expressions of a body matcher.
+func (b *BodyMatcher) compile() error {
+	if b.Value != nil {
+		if err := b.Value.compile(); err != nil {
+			return err
+		}
+	}
+	if b.Matches == "" {
+		return nil
+	}
+	re, err := regexp.Compile(b.Matches)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	b.re = re
+	return nil
+}
+
+// match reports whether a value satisfies the matcher. present tells if the value exists.
+func (m Matcher) match(value string, present bool) bool {
+	if m.Absent {
+		return !present
+	}
+	if !present {
+		return false
+	}
+	if m.EqualTo != nil && value != *m.EqualTo {
+		return false
+	}
+	if m.Contains != "" && !strings.Contains(value, m.Contains) {
+		return false
+	}
+	if m.re != nil && !m.re.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+// describe returns a short human-readable form of the matcher.
+func (m Matcher) describe() string {
+	switch {
+	case m.Absent:
+		return "absent"
+	case m.EqualTo != nil:
+		return fmt.Sprintf("equalTo %q", *m.EqualTo)
+	case m.Contains != "":
+		return fmt.Sprintf("contains %q", m.Contains)
+	case m.Matches != "":
+		return fmt.Sprintf("matches %q", m.Matches)
+	}
+	return "present"
+}
+
+// stubMatch is the result of evaluating a stub against a request.
+type stubMatch struct {
+	stub       *Stub
+	params     map[string]string
+	mismatches []string
+}
+
+// evaluate checks every predicate of the stub and collects the ones that failed.
+func (s *Stub) evaluate(c *fiber.Ctx, body interface{}, bodyErr error, states map[string]string) stubMatch {
+	result := stubMatch{stub: s}
+	req := s.Request
+
+	if s.Scenario != "" && s.RequiredState != "" && states[s.Scenario] != s.RequiredState {
+		result.mismatches = append(result.mismatches, fmt.Sprintf("scenario %s: expected state %q, current %q", s.Scenario, s.RequiredState, states[s.Scenario]))
+	}
+
+	if req.Method != "" && !strings.EqualFold(req.Method, "ANY") && !strings.EqualFold(req.Method, c.Method()) {
+		result.mismatches = append(result.mismatches, fmt.Sprintf("method: expected %s", strings.ToUpper(req.Method)))
+	}
+
+	path := c.Path()
+	if req.Path != "" {
+		params, ok := matchPath(req.Path, path)
+		if !ok {
+			result.mismatches = append(result.mismatches, fmt.Sprintf("path: expected %s", req.Path))
+		}
+		result.params = params
+	}
+	if req.pathRe != nil {
+		if !req.pathRe.MatchString(path) {
+			result.mismatches = append(result.mismatches, fmt.Sprintf("path: expected to match %q", req.PathPattern))
+		}
+	}
+
+	for name, m := range req.Headers {
+		value := c.Get(name)
+		if !m.match(value, value != "") {
+			result.mismatches = append(result.mismatches, fmt.Sprintf("header %s: expected %s", name, m.describe()))
+		}
+	}
+
+	for name, m := range req.Query {
+		value := c.Query(name)
+		present := c.Context().QueryArgs().Has(name)
+		if !m.match(value, present) {
+			result.mismatches = append(result.mismatches, fmt.Sprintf("query %s: expected %s", name, m.describe()))
+		}
+	}
+
+	for _, bm := range req.Body {
+		if reason := bm.check(c.Body(), body, bodyErr); reason != "" {
+			result.mismatches = append(result.mismatches, "body: "+reason)
+		}
+	}
+
+	return result
+}
+
+// check evaluates a body predicate and returns the reason it failed, or "" on success.
+func (b BodyMatcher) check(raw []byte, body interface{}, bodyErr error) string {
+	if b.re != nil && !b.re.Match(raw) {
+		return fmt.Sprintf("expected to match %q", b.Matches)
+	}
+
+	needsJSON := b.EqualToJSON != nil || b.Partial != nil || b.JSONPath != ""
+	if !needsJSON {
+		return ""
+	}
+	if bodyErr != nil {
+		return "expected a JSON body"
+	}
+
+	if b.EqualToJSON != nil && !reflect.DeepEqual(normalizeJSON(b.EqualToJSON), body) {
+		return "expected JSON equal to stub definition"
+	}
+	if b.Partial != nil && !containsJSON(body, normalizeJSON(b.Partial)) {
+		return "expected JSON containing stub definition"
+	}
+	if b.JSONPath != "" {
+		values := lookupJSONPath(body, b.JSONPath)
+		if len(values) == 0 {
+			if b.Value != nil && b.Value.Absent {
+				return ""
+			}
+			return fmt.Sprintf("jsonPath %s not found", b.JSONPath)
+		}
+		if b.Value != nil {
+			for _, v := range values {
+				if b.Value.match(jsonString(v), true) {
+					return ""
+				}
+			}
+			return fmt.Sprintf("jsonPath %s: expected %s", b.JSONPath, b.Value.describe())
+		}
+	}
+	return ""
+}
+
+// matchStub returns the highest priority stub matching the request.
+// When nothing matches, every candidate is returned ordered by closeness.
+func (e *Engine) matchStub(c *fiber.Ctx) (*stubMatch, []stubMatch) {
+	body, bodyErr := parseJSONBody(c.Body())
+	states := e.scenarios.snapshot()
+
+	candidates := make([]stubMatch, 0, len(e.stubs))
+	for i := range e.stubs {
+		m := e.stubs[i].evaluate(c, body, bodyErr, states)
+		if len(m.mismatches) == 0 {
+			return &m, nil
+		}
+		candidates = append(candidates, m)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].mismatches) < len(candidates[j].mismatches)
+	})
+	return nil, candidates
+}
+
+// stubMiddleware answers requests matched by a stub, before the generated resource routes.
+func (e *Engine) stubMiddleware(c *fiber.Ctx) error {
+	for {
+		match, _ := e.matchStub(c)
+		if match == nil {
+			return c.Next()
+		}
+		// Leave the required state in one step, so concurrent requests cannot both take the transition
+		stub := match.stub
+		if stub.Scenario == "" || stub.NewState == "" || e.scenarios.transition(stub.Scenario, stub.RequiredState, stub.NewState) {
+			return e.sendStubResponse(c, match)
+		}
+		// Another request moved the scenario since the match; match again
+	}
+}
+
+// sendStubResponse writes the stub response to the client.
+func (e *Engine) sendStubResponse(c *fiber.Ctx, match *stubMatch) error {
+	resp := match.stub.Response
+
+	if resp.DelayMs > 0 {
+		time.Sleep(time.Duration(resp.DelayMs) * time.Millisecond)
+	}
+
+	status := resp.Status
+	if status == 0 {
+		status = fiber.StatusOK
+	}
+
+	headers, body, text := resp.Headers, resp.Body, resp.Text
+	if resp.Template {
+		var err error
+		headers, body, text, err = e.renderStubResponse(c, match)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "template_error",
+				"message": err.Error(),
+				"stub":    match.stub.ID,
+			})
+		}
+	}
+
+	for k, v := range headers {
+		c.Set(k, v)
+	}
+	c.Status(status)
+
+	if body != nil {
+		return c.JSON(body)
+	}
+	return c.SendString(text)
+}
+
+// renderStubResponse renders the templated headers, body and text of a stub response.
+func (e *Engine) renderStubResponse(c *fiber.Ctx, match *stubMatch) (map[string]string, interface{}, string, error) {
+	resp := match.stub.Response
+	data := newTemplateData(c, match.params)
+
+	headers := make(map[string]string, len(resp.Headers))
+	for k, v := range resp.Headers {
+		rendered, err := e.renderTemplate(v, data)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		headers[k] = rendered
+	}
+
+	body, err := e.renderTemplateValue(resp.Body, data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	text, err := e.renderTemplate(resp.Text, data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return headers, body, text, nil
+}
+
+// handleNotFound runs after every route. It forwards the request upstream when a
+// proxy is configured, and otherwise reports the closest stubs.
+func (e *Engine) handleNotFound(c *fiber.Ctx) error {
+	if e.proxy != nil {
+		return e.handleProxy(c)
+	}
+	if len(e.stubs) == 0 {
+		return c.Next()
+	}
+
+	_, candidates := e.matchStub(c)
+	if len(candidates) > 3 {
+		candidates = candidates[:3]
+	}
+
+	closest := make([]fiber.Map, 0, len(candidates))
+	for _, cand := range candidates {
+		closest = append(closest, fiber.Map{
+			"id":         cand.stub.ID,
+			"method":     cand.stub.Request.Method,
+			"path":       cand.stub.Request.Path + cand.stub.Request.PathPattern,
+			"mismatches": cand.mismatches,
+		})
+	}
+
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "no_stub_matched",
+		"message": fmt.Sprintf("No stub matched %s %s", c.Method(), c.Path()),
+		"closest": closest,
+	})
+}
+
+// matchPath matches a path against a pattern with :params and an optional trailing *.
+func matchPath(pattern, path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	params := make(map[string]string)
+
+	for i, part := range patternParts {
+		if part == "*" && i == len(patternParts)-1 {
+			params["*"] = strings.Join(pathParts[min(i, len(pathParts)):], "/")
+			return params, true
+		}
+		if i >= len(pathParts) {
+			return params, false
+		}
+		if strings.HasPrefix(part, ":") {
+			params[part[1:]] = pathParts[i]
+			continue
+		}
+		if part != pathParts[i] {
+			return params, false
+		}
+	}
+
+	return params, len(patternParts) == len(pathParts)
+}
+
+// parseJSONBody decodes a request body, returning an error for empty or invalid JSON.
+func parseJSONBody(raw []byte) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty body")
+	}
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// normalizeJSON round-trips a value through encoding/json so numbers compare as float64.
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// containsJSON reports whether actual contains every field of expected (recursively).
+func containsJSON(actual, expected interface{}) bool {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range exp {
+			if !containsJSON(act[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, ev := range exp {
+			found := false
+			for _, av := range act {
+				if containsJSON(av, ev) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(actual, expected)
+	}
+}
+
+// lookupJSONPath evaluates a simple JSONPath ($.a.b[0].c, [*] wildcards) and returns all matches.
+func lookupJSONPath(doc interface{}, path string) []interface{} {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.ReplaceAll(path, "[", ".[")
+
+	current := []interface{}{doc}
+	for _, token := range strings.Split(path, ".") {
+		if token == "" {
+			continue
+		}
+		next := make([]interface{}, 0)
+		for _, node := range current {
+			switch {
+			case token == "[*]" || token == "*":
+				switch n := node.(type) {
+				case []interface{}:
+					next = append(next, n...)
+				case map[string]interface{}:
+					for _, v := range n {
+						next = append(next, v)
+					}
+				}
+			case strings.HasPrefix(token, "["):
+				idx, err := strconv.Atoi(strings.Trim(token, "[]"))
+				if arr, ok := node.([]interface{}); ok && err == nil && idx >= 0 && idx < len(arr) {
+					next = append(next, arr[idx])
+				}
+			default:
+				if m, ok := node.(map[string]interface{}); ok {
+					if v, exists := m[token]; exists {
+						next = append(next, v)
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// jsonString formats a JSON value for string matchers.
+func jsonString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
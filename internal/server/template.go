@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/MiguelVivar/insta-mock/internal/generator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TemplateData is the data available to response templates.
+type TemplateData struct {
+	Method  string
+	Path    string
+	Params  map[string]string
+	Query   map[string]string
+	Headers map[string]string
+	Body    interface{}
+}
+
+// newTemplateData captures the parts of a request exposed to templates.
+func newTemplateData(c *fiber.Ctx, params map[string]string) TemplateData {
+	headers := make(map[string]string)
+	for k, v := range c.GetReqHeaders() {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+
+	body, _ := parseJSONBody(c.Body())
+	if params == nil {
+		params = make(map[string]string)
+	}
+
+	return TemplateData{
+		Method:  c.Method(),
+		Path:    c.Path(),
+		Params:  params,
+		Query:   c.Queries(),
+		Headers: headers,
+		Body:    body,
+	}
+}
+
+// templateFuncs returns the helper functions available to response templates.
+func (e *Engine) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"uuid": func() string { return uuid.New().String() },
+		"now":  func() string { return time.Now().UTC().Format(time.RFC3339) },
+		"fake": func(fieldType string) interface{} { return generator.GenerateByType(fieldType) },
+		"find": e.templateFind,
+		"all":  e.templateAll,
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"default": func(def, v interface{}) interface{} {
+			if v == nil || v == "" {
+				return def
+			}
+			return v
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+}
+
+// templateFind returns an item of a resource by id, or nil when it does not exist.
+func (e *Engine) templateFind(resource string, id interface{}) map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	want := idString(id)
+	for _, item := range e.store[resource] {
+		if e.ids.id(resource, item) == want {
+			return item
+		}
+	}
+	return nil
+}
+
+// templateAll returns every item of a resource.
+func (e *Engine) templateAll(resource string) []map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	items := make([]map[string]interface{}, len(e.store[resource]))
+	copy(items, e.store[resource])
+	return items
+}
+
+// renderTemplate executes a single template string.
+func (e *Engine) renderTemplate(text string, data TemplateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	funcs := e.templateFuncs()
+	funcs[blankFunc] = func(v interface{}) interface{} {
+		if v == nil {
+			return ""
+		}
+		return v
+	}
+	tmpl, err := template.New("response").Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %w", text, err)
+	}
+	blankMissing(tmpl.Tree.Root)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("template %q failed: %w", text, err)
+	}
+	return buf.String(), nil
+}
+
+// blankFunc is the template function that blankMissing pipes printed values through.
+const blankFunc = "_blank"
+
+// blankMissing makes the actions of a parsed template print missing and null values as nothing
+// rather than "<no value>", by ending each printing pipeline with blankFunc.
+func blankMissing(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			blankMissing(child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 {
+			blank := parse.NewIdentifier(blankFunc).SetPos(n.Pos)
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{blank}})
+		}
+	case *parse.IfNode:
+		blankMissing(n.List)
+		blankMissing(n.ElseList)
+	case *parse.RangeNode:
+		blankMissing(n.List)
+		blankMissing(n.ElseList)
+	case *parse.WithNode:
+		blankMissing(n.List)
+		blankMissing(n.ElseList)
+	}
+}
+
+// renderTemplateValue renders every string inside a JSON value, keeping its structure.
+func (e *Engine) renderTemplateValue(value interface{}, data TemplateData) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return e.renderTemplate(v, data)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			rendered, err := e.renderTemplateValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := e.renderTemplateValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// itemVersion is the version of an item, bumped on every change.
+type itemVersion struct {
+	version  int
+	modified time.Time
+}
+
+// resourceVersion identifies the state of a whole resource, for list ETags.
+type resourceVersion struct {
+	rev      int64 // Value of the engine-wide counter at the last change
+	modified time.Time
+}
+
+// versionStore tracks the versions of items and resources. It is guarded by the store lock.
+type versionStore struct {
+	items     map[string]map[string]itemVersion // Resource → item id → version
+	resources map[string]resourceVersion
+	rev       int64 // Engine-wide change counter, so list ETags never repeat across reloads
+}
+
+// newVersionStore creates an empty version store.
+func newVersionStore() *versionStore {
+	return &versionStore{
+		items:     make(map[string]map[string]itemVersion),
+		resources: make(map[string]resourceVersion),
+	}
+}
+
+// rebuild versions every item of a (re)loaded store. Items that already had a version
+// are bumped, so ETags issued before a reload never match again.
+func (v *versionStore) rebuild(store map[string][]map[string]interface{}, ids *idGenerator) {
+	now := time.Now().UTC()
+	v.rev++
+	items := make(map[string]map[string]itemVersion, len(store))
+	resources := make(map[string]resourceVersion, len(store))
+	for resource, list := range store {
+		items[resource] = make(map[string]itemVersion, len(list))
+		for _, item := range list {
+			id := ids.id(resource, item)
+			items[resource][id] = itemVersion{version: v.items[resource][id].version + 1, modified: now}
+		}
+		resources[resource] = resourceVersion{rev: v.rev, modified: now}
+	}
+	v.items, v.resources = items, resources
+}
+
+// update bumps the versions of an item and its resource after a change.
+func (v *versionStore) update(resource, id string) {
+	now := time.Now().UTC()
+	v.rev++
+	v.resources[resource] = resourceVersion{rev: v.rev, modified: now}
+
+	// Deleted items keep counting, so an id created again never reuses an old ETag
+	if v.items[resource] == nil {
+		v.items[resource] = make(map[string]itemVersion)
+	}
+	v.items[resource][id] = itemVersion{version: v.items[resource][id].version + 1, modified: now}
+}
+
+// item returns the version of an item; unknown items are at version 1.
+func (v *versionStore) item(resource, id string) itemVersion {
+	if iv, ok := v.items[resource][id]; ok {
+		return iv
+	}
+	return itemVersion{version: 1}
+}
+
+// etag returns the strong ETag of an item version.
+func (iv itemVersion) etag() string {
+	return `"` + strconv.Itoa(iv.version) + `"`
+}
+
+// etag returns the weak ETag of a resource's lists; their bodies vary with the query.
+func (rv resourceVersion) etag() string {
+	return `W/"` + strconv.FormatInt(rv.rev, 10) + `"`
+}
+
+// etagVariantKey is the context key under which withFormat names the representation of a response.
+const etagVariantKey = "etagVariant"
+
+// representationETag tags a version ETag with the representation a request gets, when that is
+// not plain JSON ("3" → "3-jsonapi"), so each format and projection of an item has its own ETag.
+func representationETag(c *fiber.Ctx, etag string) string {
+	variant, _ := c.Locals(etagVariantKey).(string)
+	if variant == "" {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + variant + `"`
+}
+
+// versionETag strips the representation from an ETag ("3-jsonapi" → "3").
+func versionETag(etag string) string {
+	if i := strings.IndexByte(etag, '-'); i >= 0 {
+		return etag[:i] + `"`
+	}
+	return etag
+}
+
+// setValidators sets the ETag and Last-Modified headers of a successful response.
+func setValidators(c *fiber.Ctx, etag string, modified time.Time) {
+	c.Set(fiber.HeaderETag, representationETag(c, etag))
+	if !modified.IsZero() {
+		c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+	}
+}
+
+// notModified reports whether a GET can be answered with 304: If-None-Match lists the ETag,
+// or, without If-None-Match, nothing changed since If-Modified-Since.
+func notModified(c *fiber.Ctx, etag string, modified time.Time) bool {
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		return etagListed(noneMatch, representationETag(c, etag), true)
+	}
+	if since := c.Get(fiber.HeaderIfModifiedSince); since != "" && !modified.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// preconditionFailed reports whether an If-Match header rules out changing an item with this ETag.
+func preconditionFailed(c *fiber.Ctx, etag string) bool {
+	return ifMatchFails(c.Get(fiber.HeaderIfMatch), etag)
+}
+
+// ifMatchFails reports whether an If-Match value rules out changing an item with this ETag.
+func ifMatchFails(ifMatch, etag string) bool {
+	return ifMatch != "" && !etagListed(ifMatch, etag, false)
+}
+
+// etagListed reports whether a list of ETags (or *) from a conditional header contains etag.
+// Weak comparison ignores the W/ prefix; strong comparison never matches weak ETags and
+// accepts the ETag of any representation of the version, as writes change the item itself.
+func etagListed(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if versionETag(candidate) == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sendPreconditionFailed answers a write whose If-Match does not match the item.
+func sendPreconditionFailed(c *fiber.Ctx, resource, id, etag string) error {
+	return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"error":   "precondition_failed",
+		"message": preconditionMessage(resource, id, etag, c.Get(fiber.HeaderIfMatch)),
+	})
+}
+
+// sendList answers a successful list request with the validators of its resource, or with
+// 304 when the client's copy is current.
+func sendList(c *fiber.Ctx, rv resourceVersion, items interface{}) error {
+	setValidators(c, rv.etag(), rv.modified)
+	if notModified(c, rv.etag(), rv.modified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(items)
+}
+
+// preconditionMessage explains why an If-Match value does not match an item.
+func preconditionMessage(resource, id, etag, ifMatch string) string {
+	return fmt.Sprintf("%s with id '%s' is at version %s, not %s", resource, id, etag, ifMatch)
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// EventsSocketPath streams store changes as JSON over WebSocket.
+const EventsSocketPath = "/_ws"
+
+// GraphQL over WebSocket subprotocols: graphql-transport-ws is the current
+// protocol, graphql-ws the legacy subscriptions-transport-ws one.
+const (
+	graphQLTransportWS = "graphql-transport-ws"
+	graphQLLegacyWS    = "graphql-ws"
+)
+
+// registerSocketRoutes registers the plain event stream.
+// GraphQL subscriptions are upgraded from GET /graphql in handleGraphQLUpgrade.
+func (e *Engine) registerSocketRoutes() {
+	e.app.Get(EventsSocketPath, websocket.New(e.handleEventsSocket))
+}
+
+// handleEventsSocket pushes every matching ChangeEvent to the client.
+// Filters: ?resource=users&id=1&type=created,deleted
+func (e *Engine) handleEventsSocket(conn *websocket.Conn) {
+	resource := conn.Query("resource")
+	id := conn.Query("id")
+	var types []string
+	if t := conn.Query("type"); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	events := e.events.subscribe()
+	defer e.events.unsubscribe(events)
+
+	// Reading detects disconnects; client messages are ignored
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case ev := <-events:
+			if !ev.matches(resource, id) || (len(types) > 0 && !containsString(types, ev.Type)) {
+				continue
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleGraphQLUpgrade upgrades GraphQL WebSocket requests and passes plain HTTP requests on.
+func (e *Engine) handleGraphQLUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return websocket.New(e.handleGraphQLSocket, websocket.Config{
+		Subprotocols: []string{graphQLTransportWS, graphQLLegacyWS},
+	})(c)
+}
+
+// graphQLMessage is a GraphQL over WebSocket protocol message.
+type graphQLMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// graphQLSocket serializes writes and tracks the running operations of a connection.
+type graphQLSocket struct {
+	conn       *websocket.Conn
+	legacy     bool
+	writeMu    sync.Mutex
+	mu         sync.Mutex
+	operations map[string]context.CancelFunc
+}
+
+// send writes a message, translating message types for the legacy protocol.
+func (s *graphQLSocket) send(msg graphQLMessage) {
+	if s.legacy {
+		switch msg.Type {
+		case "next":
+			msg.Type = "data"
+		case "error":
+			if errs, ok := msg.Payload.([]interface{}); ok && len(errs) > 0 {
+				msg.Payload = errs[0]
+			}
+		}
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.conn.WriteJSON(msg)
+}
+
+// handleGraphQLSocket runs queries, mutations and subscriptions sent over WebSocket.
+func (e *Engine) handleGraphQLSocket(conn *websocket.Conn) {
+	s := &graphQLSocket{
+		conn:       conn,
+		legacy:     conn.Subprotocol() == graphQLLegacyWS,
+		operations: make(map[string]context.CancelFunc),
+	}
+	defer func() {
+		s.mu.Lock()
+		for _, cancel := range s.operations {
+			cancel()
+		}
+		s.mu.Unlock()
+	}()
+
+	for {
+		var msg struct {
+			ID      string         `json:"id"`
+			Type    string         `json:"type"`
+			Payload graphQLRequest `json:"payload"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			s.send(graphQLMessage{Type: "connection_ack"})
+			if s.legacy {
+				s.send(graphQLMessage{Type: "ka"})
+			}
+		case "ping":
+			s.send(graphQLMessage{Type: "pong"})
+		case "subscribe", "start":
+			go e.runGraphQLOperation(s, msg.ID, msg.Payload)
+		case "complete", "stop":
+			s.mu.Lock()
+			if cancel, ok := s.operations[msg.ID]; ok {
+				cancel()
+				delete(s.operations, msg.ID)
+			}
+			s.mu.Unlock()
+		case "connection_terminate":
+			return
+		}
+	}
+}
+
+// runGraphQLOperation executes one operation and streams its results until it completes or is cancelled.
+func (e *Engine) runGraphQLOperation(s *graphQLSocket, id string, req graphQLRequest) {
+	e.mu.RLock()
+	schema, schemaErr := e.graphQLSchema, e.graphQLErr
+	e.mu.RUnlock()
+	if schemaErr != nil {
+		s.send(graphQLMessage{ID: id, Type: "error", Payload: []interface{}{
+			fiber.Map{"message": "GraphQL schema unavailable: " + schemaErr.Error()},
+		}})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if _, exists := s.operations[id]; exists {
+		s.mu.Unlock()
+		cancel()
+		_ = s.conn.Close()
+		return
+	}
+	s.operations[id] = cancel
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.operations, id)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	params := graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}
+
+	if !isSubscription(req.Query, req.OperationName) {
+		result := graphql.Do(params)
+		if ctx.Err() == nil {
+			s.send(graphQLMessage{ID: id, Type: "next", Payload: result})
+			s.send(graphQLMessage{ID: id, Type: "complete"})
+		}
+		return
+	}
+
+	for result := range graphql.Subscribe(params) {
+		if ctx.Err() != nil {
+			continue // Drain until the subscription stops
+		}
+		if result.Data == nil && result.HasErrors() {
+			errs := make([]interface{}, len(result.Errors))
+			for i, err := range result.Errors {
+				errs[i] = err
+			}
+			s.send(graphQLMessage{ID: id, Type: "error", Payload: errs})
+			cancel()
+			continue
+		}
+		s.send(graphQLMessage{ID: id, Type: "next", Payload: result})
+	}
+	if ctx.Err() == nil {
+		s.send(graphQLMessage{ID: id, Type: "complete"})
+	}
+}
+
+// isSubscription reports whether the selected operation of a document is a subscription.
+func isSubscription(query, operationName string) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation == ast.OperationTypeSubscription
+		}
+	}
+	return false
+}